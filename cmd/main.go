@@ -18,8 +18,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"strings"
+	"time"
 
 	"net/http"
 	"os"
@@ -40,6 +42,11 @@ import (
 	driver "github.com/kubernetes-sigs/ibm-vpc-block-csi-driver/pkg/ibmcsidriver"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 func init() {
@@ -49,11 +56,13 @@ func init() {
 }
 
 var (
-	endpoint             = flag.String("endpoint", "unix:/tmp/csi.sock", "CSI endpoint")
-	metricsAddress       = flag.String("metrics-address", "0.0.0.0:9080", "Metrics address")
-	extraVolumeLabelsStr = flag.String("extra-labels", "", "Extra labels to tag all volumes created by driver. It is a comma separated list of key value pairs like '<key1>:<value1>,<key2>:<value2>'.")
-	vendorVersion        string
-	logger               *zap.Logger
+	endpoint                  = flag.String("endpoint", "unix:/tmp/csi.sock", "CSI endpoint")
+	metricsAddress            = flag.String("metrics-address", "0.0.0.0:9080", "Metrics address")
+	extraVolumeLabelsStr      = flag.String("extra-labels", "", "Extra labels to tag all volumes created by driver. It is a comma separated list of key value pairs like '<key1>:<value1>,<key2>:<value2>'.")
+	snapshotOnDeleteRetention = flag.Duration("snapshot-on-delete-retention", 7*24*time.Hour, "Retention period of the snapshots taken of the volumes of the storage classes with snapshotOnDelete, the expired snapshots are deleted by the controller. Snapshots are kept forever if it is 0.")
+	maxConcurrentFormat       = flag.Int("max-concurrent-format", 2, "Maximum number of volumes formatted at the same time on a node. There is no limit if it is 0.")
+	vendorVersion             string
+	logger                    *zap.Logger
 )
 
 func main() {
@@ -109,12 +118,27 @@ func handle(logger *zap.Logger) {
 	}
 
 	statUtil := &(driver.VolumeStatUtils{})
+	deviceUtil := driver.NewDeviceResolver()
 
-	err = ibmCSIDriver.SetupIBMCSIDriver(ibmcloudProvider, mounter, statUtil, nil, &nodeInfo, logger, csiConfig.CSIDriverName, vendorVersion)
+	err = ibmCSIDriver.SetupIBMCSIDriver(ibmcloudProvider, mounter, statUtil, deviceUtil, nil, &nodeInfo, logger, csiConfig.CSIDriverName, vendorVersion)
 	if err != nil {
 		logger.Fatal("Failed to initialize driver...", zap.Error(err))
 	}
 
+	ibmCSIDriver.SetMaxConcurrentFormat(*maxConcurrentFormat)
+	if len(nodeName) != 0 {
+		// Events are recorded on the node object, which is looked up for its UID
+		node, err := k8sClient.Clientset.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+		if err != nil {
+			logger.Warn("Failed to get the node, events of the node are not recorded", zap.String("NodeName", nodeName), zap.Error(err))
+		} else {
+			eventBroadcaster := record.NewBroadcaster()
+			eventBroadcaster.StartRecordingToSink(&typedCoreV1.EventSinkImpl{Interface: k8sClient.Clientset.CoreV1().Events("")})
+			recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: csiConfig.CSIDriverName, Host: nodeName})
+			ibmCSIDriver.SetEventRecorder(recorder, &v1.ObjectReference{Kind: "Node", Name: node.Name, UID: node.UID})
+		}
+	}
+
 	logger.Info("Successfully initialized driver...")
 	serveMetrics()
 	// Start PV watcher if its controller POD
@@ -122,6 +146,9 @@ func handle(logger *zap.Logger) {
 		pvwatcher := watcher.New(logger, csiConfig.CSIDriverName, csiConfig.CSIProviderVolumeType, ibmcloudProvider)
 		go pvwatcher.Start()
 	}
+	if strings.Contains(os.Getenv("POD_NAME"), "csi-controller") {
+		ibmCSIDriver.StartRetentionSnapshotSweeper(context.Background(), *snapshotOnDeleteRetention)
+	}
 
 	ibmCSIDriver.Run(*endpoint)
 }
@@ -136,4 +163,5 @@ func serveMetrics() {
 	}()
 	metrics.RegisterAll(csiConfig.CSIDriverGithubName)
 	libMetrics.RegisterAll()
+	driver.RegisterMetrics()
 }
@@ -18,6 +18,9 @@ require (
 	golang.org/x/sys v0.31.0
 	google.golang.org/grpc v1.65.0
 	google.golang.org/protobuf v1.36.1
+	k8s.io/api v0.32.10
+	k8s.io/apimachinery v0.32.10
+	k8s.io/client-go v0.32.10
 	k8s.io/klog/v2 v2.130.1
 	k8s.io/kubernetes v1.32.10
 	k8s.io/mount-utils v0.32.10
@@ -97,11 +100,8 @@ require (
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	k8s.io/api v0.32.10 // indirect
 	k8s.io/apiextensions-apiserver v0.32.10 // indirect
-	k8s.io/apimachinery v0.32.10 // indirect
 	k8s.io/apiserver v0.32.10 // indirect
-	k8s.io/client-go v0.32.10 // indirect
 	k8s.io/component-base v0.32.10 // indirect
 	k8s.io/controller-manager v0.32.10 // indirect
 	k8s.io/kube-openapi v0.0.0-20241105132330-32ad38e42d3f // indirect
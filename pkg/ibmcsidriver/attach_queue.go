@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ibmcsidriver ...
+package ibmcsidriver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// attachQueueDepth is the number of attach and detach operations queued or running in the controller
+var attachQueueDepth = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "attach_detach_queue_depth",
+		Help: "Number of volume attach and detach operations queued or running in the controller.",
+	},
+)
+
+// RegisterMetrics registers the metrics of the driver
+func RegisterMetrics() {
+	prometheus.MustRegister(attachQueueDepth)
+}
+
+// attachQueue queues the attach and detach operations of the nodes. Operations of different volumes
+// of a node run at the same time, up to maxConcurrent of them, so that the waits for the attachments
+// overlap, while the operations of a volume run one at a time in the queued order.
+type attachQueue struct {
+	mutex         sync.Mutex
+	nodes         map[string]*nodeAttachQueue
+	maxConcurrent int
+}
+
+// nodeAttachQueue is the queue of a node
+type nodeAttachQueue struct {
+	slots   chan struct{}
+	volumes map[string]*volumeAttachQueue
+	pending int
+}
+
+// volumeAttachQueue is the queue of a volume on a node
+type volumeAttachQueue struct {
+	turn    chan struct{}
+	pending int
+}
+
+// acquire queues an operation of the volume on the node and waits for its turn, the returned
+// release function must be called once the operation is done. Waiting ends with the context
+// error if the context is done before the turn comes.
+func (q *attachQueue) acquire(ctx context.Context, nodeID string, volumeID string) (func(), error) {
+	nq, vq := q.enqueue(nodeID, volumeID)
+
+	// Volume turn is taken first so that the operations of a volume keep their order
+	select {
+	case vq.turn <- struct{}{}:
+	case <-ctx.Done():
+		q.dequeue(nodeID, volumeID)
+		return nil, ctx.Err()
+	}
+
+	select {
+	case nq.slots <- struct{}{}:
+	case <-ctx.Done():
+		<-vq.turn
+		q.dequeue(nodeID, volumeID)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			<-nq.slots
+			<-vq.turn
+			q.dequeue(nodeID, volumeID)
+		})
+	}
+	return release, nil
+}
+
+// enqueue records the operation in the queues of the node and of the volume, creating them if needed
+func (q *attachQueue) enqueue(nodeID string, volumeID string) (*nodeAttachQueue, *volumeAttachQueue) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.nodes == nil {
+		q.nodes = make(map[string]*nodeAttachQueue)
+	}
+	nq, ok := q.nodes[nodeID]
+	if !ok {
+		maxConcurrent := q.maxConcurrent
+		if maxConcurrent <= 0 {
+			maxConcurrent = MaxConcurrentAttachPerNode
+		}
+		nq = &nodeAttachQueue{
+			slots:   make(chan struct{}, maxConcurrent),
+			volumes: make(map[string]*volumeAttachQueue),
+		}
+		q.nodes[nodeID] = nq
+	}
+	vq, ok := nq.volumes[volumeID]
+	if !ok {
+		vq = &volumeAttachQueue{turn: make(chan struct{}, 1)}
+		nq.volumes[volumeID] = vq
+	}
+	nq.pending++
+	vq.pending++
+	attachQueueDepth.Inc()
+	return nq, vq
+}
+
+// dequeue removes the operation from the queues, the queues left empty are dropped
+func (q *attachQueue) dequeue(nodeID string, volumeID string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	nq := q.nodes[nodeID]
+	vq := nq.volumes[volumeID]
+	vq.pending--
+	if vq.pending == 0 {
+		delete(nq.volumes, volumeID)
+	}
+	nq.pending--
+	if nq.pending == 0 {
+		delete(q.nodes, nodeID)
+	}
+	attachQueueDepth.Dec()
+}
+
+// depth returns the number of operations queued or running for the node
+func (q *attachQueue) depth(nodeID string) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if nq, ok := q.nodes[nodeID]; ok {
+		return nq.pending
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ibmcsidriver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	cloudProvider "github.com/IBM/ibmcloud-volume-vpc/pkg/ibmcloudprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachQueueVolumesOfNodeRunTogether(t *testing.T) {
+	q := &attachQueue{}
+	var releases []func()
+	for i := 0; i < MaxConcurrentAttachPerNode; i++ {
+		release, err := q.acquire(context.Background(), "node-1", fmt.Sprintf("vol-%d", i))
+		assert.Nil(t, err)
+		releases = append(releases, release)
+	}
+	assert.Equal(t, MaxConcurrentAttachPerNode, q.depth("node-1"))
+
+	// Node is full, next volume waits till the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.acquire(ctx, "node-1", "vol-extra")
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// Other nodes are not affected
+	release, err := q.acquire(context.Background(), "node-2", "vol-extra")
+	assert.Nil(t, err)
+	release()
+
+	for _, release := range releases {
+		release()
+	}
+	assert.Equal(t, 0, q.depth("node-1"))
+	assert.Empty(t, q.nodes)
+}
+
+func TestAttachQueueMaxConcurrent(t *testing.T) {
+	q := &attachQueue{maxConcurrent: 1}
+	release, err := q.acquire(context.Background(), "node-1", "vol-1")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = q.acquire(ctx, "node-1", "vol-2")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	release()
+}
+
+func TestGetMaxConcurrentAttachPerNode(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	assert.Equal(t, MaxConcurrentAttachPerNode, getMaxConcurrentAttachPerNode(logger))
+	t.Setenv("MAX_CONCURRENT_ATTACH_PER_NODE", "8")
+	assert.Equal(t, 8, getMaxConcurrentAttachPerNode(logger))
+	t.Setenv("MAX_CONCURRENT_ATTACH_PER_NODE", "0")
+	assert.Equal(t, MaxConcurrentAttachPerNode, getMaxConcurrentAttachPerNode(logger))
+	t.Setenv("MAX_CONCURRENT_ATTACH_PER_NODE", "many")
+	assert.Equal(t, MaxConcurrentAttachPerNode, getMaxConcurrentAttachPerNode(logger))
+}
+
+func TestAttachQueueVolumeOrder(t *testing.T) {
+	q := &attachQueue{}
+	release, err := q.acquire(context.Background(), "node-1", "vol-1")
+	assert.Nil(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := q.acquire(context.Background(), "node-1", "vol-1")
+		assert.Nil(t, err)
+		close(acquired)
+		second()
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second operation of the volume ran before the first one was done")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Equal(t, 2, q.depth("node-1"))
+
+	release()
+	// Releasing more than once has no effect
+	release()
+	<-acquired
+}
+
+func TestAttachQueueCancel(t *testing.T) {
+	q := &attachQueue{}
+	release, err := q.acquire(context.Background(), "node-1", "vol-1")
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = q.acquire(ctx, "node-1", "vol-1")
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, q.depth("node-1"))
+
+	release()
+	assert.Empty(t, q.nodes)
+}
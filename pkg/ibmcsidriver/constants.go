@@ -30,18 +30,9 @@ const (
 	// Profile ...
 	Profile = "profile"
 
-	// IopsPerGB ...
-	IopsPerGB = "iopsPerGB"
-
-	//SizeIopsRange ...
-	SizeIopsRange = "sizeIOPSRange"
-
 	// IOPS per PVC
 	IOPS = "iops"
 
-	// SizeRangeSupported ...
-	SizeRangeSupported = "sizeRange"
-
 	// BillingType ...
 	BillingType = "billingType"
 
@@ -57,6 +48,30 @@ const (
 	// Zone ...
 	Zone = "zone"
 
+	// Zones comma separated list of zones allowed for the volumes of a storage class
+	Zones = "zones"
+
+	// MaxConcurrentAttachPerNode ... default number of attach and detach operations run at the same time for a node,
+	// MAX_CONCURRENT_ATTACH_PER_NODE overrides it
+	MaxConcurrentAttachPerNode = 4
+
+	// SnapshotOnDelete ... keep a snapshot of the volume for the retention period of the controller when it is deleted
+	SnapshotOnDelete = "snapshotOnDelete"
+
+	// SnapshotOnDeleteTag ... volume tag which records the SnapshotOnDelete opt-in of the storage class
+	SnapshotOnDeleteTag = "csi-snapshot-on-delete"
+
+	// FormatRestoredVolume ... format the volume restored from a snapshot which has no filesystem, instead of refusing
+	// to stage it, e.g for the snapshots of the volumes never formatted
+	FormatRestoredVolume = "formatRestoredVolume"
+
+	// SharedVolume ... allow the clusters other than the owner to delete and expand the volume
+	SharedVolume = "sharedVolume"
+
+	// SharedVolumeTag ... volume tag which records the SharedVolume opt-out of the cluster ownership check,
+	// it can also be added by the tags attribute of a static PV
+	SharedVolumeTag = "csi-shared-volume"
+
 	// Region ...
 	Region = "region"
 
@@ -66,6 +81,15 @@ const (
 	// CustomProfile ...
 	CustomProfile = "custom"
 
+	// GeneralPurposeProfile ...
+	GeneralPurposeProfile = "general-purpose"
+
+	// FiveIopsTierProfile ...
+	FiveIopsTierProfile = "5iops-tier"
+
+	// TenIopsTierProfile ...
+	TenIopsTierProfile = "10iops-tier"
+
 	// SDPProfile ...
 	SDPProfile = "sdp"
 
@@ -111,6 +135,44 @@ const (
 	// ClusterIDLabel ...
 	ClusterIDLabel = "clusterID"
 
+	// CopyToRegions ... regions to copy the snapshot to, not supported by the provider yet
+	CopyToRegions = "copyToRegions"
+
+	// FastRestoreZones ... zones to enable fast restore of the snapshot in, not supported by the provider yet
+	FastRestoreZones = "fastRestoreZones"
+
+	// VolumeSnapshotNameKey ... passed by snapshotter with --extra-create-metadata
+	VolumeSnapshotNameKey = "csi.storage.k8s.io/volumesnapshot/name"
+
+	// VolumeSnapshotNamespaceKey ... passed by snapshotter with --extra-create-metadata
+	VolumeSnapshotNamespaceKey = "csi.storage.k8s.io/volumesnapshot/namespace"
+
+	// VolumeSnapshotContentNameKey ... passed by snapshotter with --extra-create-metadata
+	VolumeSnapshotContentNameKey = "csi.storage.k8s.io/volumesnapshotcontent/name"
+
+	// PVCNameKey ... passed by provisioner with --extra-create-metadata
+	PVCNameKey = "csi.storage.k8s.io/pvc/name"
+
+	// PVCNamespaceKey ... passed by provisioner with --extra-create-metadata
+	PVCNamespaceKey = "csi.storage.k8s.io/pvc/namespace"
+
+	// PVNameKey ... passed by provisioner with --extra-create-metadata
+	PVNameKey = "csi.storage.k8s.io/pv/name"
+
+	// NameTemplate ... template of the volume name e.g "${pvc.namespace}-${pvc.name}"
+	NameTemplate = "nameTemplate"
+
+	// RequestNameLabel ... tag key recording the request name of the volume named by nameTemplate, so that a
+	// volume of the same name created for another request is not taken as the volume of the request
+	RequestNameLabel = "csi-request-name"
+
+	// TagTemplate ... template of the comma separated volume tags e.g "namespace:${pvc.namespace},pvc:${pvc.name}"
+	TagTemplate = "tagTemplate"
+
+	// VolumeNameMaxLen Max length of the volume name in Chars
+	// maxLength: 63 minLength: 1 pattern: ^([a-z]|[a-z][-a-z0-9]*[a-z0-9])$
+	VolumeNameMaxLen = 63
+
 	// IOPSLabel ...
 	IOPSLabel = "iops"
 
@@ -120,21 +182,66 @@ const (
 	// Generation ... just for backward compatibility
 	Generation = "generation"
 
-	// DEFAULT_SNAPSHOT_CREATE_DELAY ...
-	DEFAULT_SNAPSHOT_CREATE_DELAY = 300 //300 seconds
-
-	// MAX_SNAPSHOT_CREATE_DELAY ... This is max timeout value for csi-snapshotter
-	MAX_SNAPSHOT_CREATE_DELAY = 900 //900 seconds
+	// RC429 ... reason code of the requests throttled by provider
+	RC429 = "RC:429"
 
 	// MinimumSDPVolumeSizeInBytes ... This is minimum size require for sdp (acadia profile)
 	MinimumSDPVolumeSizeInBytes int64 = 1 * utils.GiB
 
+	// MaximumVolumeSizeInBytes ... This is maximum size allowed for tiered and custom profile
+	MaximumVolumeSizeInBytes int64 = 16000 * utils.GiB
+
+	// MaximumSDPVolumeSizeInBytes ... This is maximum size allowed for sdp (acadia profile)
+	MaximumSDPVolumeSizeInBytes int64 = 32000 * utils.GiB
+
+	// CloneSnapshotPrefix ... prefix of the internal snapshot name which is used to clone the volume
+	CloneSnapshotPrefix = "clone-"
+
+	// RetentionSnapshotPrefix ... prefix of the snapshot name which is taken of the volume on delete
+	RetentionSnapshotPrefix = "csi-retain-"
+
+	// ListVolumesMaxLimit ... max number of volumes returned by VPC in one list volumes call
+	ListVolumesMaxLimit = 100
+
+	// ListSnapshotsMaxLimit ... max number of snapshots returned by VPC in one list snapshots call
+	ListSnapshotsMaxLimit = 100
+
 	// Throughput ...
 	Throughput = "throughput"
+
+	// VolumeStatusAvailable ... volume is ready to be used
+	VolumeStatusAvailable = "available"
+
+	// VolumeStatusPending ... volume is being provisioned
+	VolumeStatusPending = "pending"
+
+	// VolumeStatusUpdating ... volume is being updated e.g expanded
+	VolumeStatusUpdating = "updating"
+
+	// VolumeStatusFailed ... volume provisioning failed
+	VolumeStatusFailed = "failed"
+
+	// VolumeStatusUnusable ... volume is not usable
+	VolumeStatusUnusable = "unusable"
+
+	// VolumeStatusPendingDeletion ... volume is being deleted
+	VolumeStatusPendingDeletion = "pending_deletion"
+
+	// VolumeNotInValidState ... error code returned by provider when created volume did not get available in time
+	VolumeNotInValidState = "VolumeNotInValidState"
+
+	// DeviceIdentityMismatch ... event reason of a device whose serial is not of the volume being staged or published
+	DeviceIdentityMismatch = "DeviceIdentityMismatch"
+
+	// DeviceIdentityUnverified ... event reason of a device without a readable serial, verified by its /dev/disk/by-id link only
+	DeviceIdentityUnverified = "DeviceIdentityUnverified"
+
+	// SnapshotRestoreWithoutFilesystem ... event reason of a volume restored from a snapshot found without a filesystem
+	SnapshotRestoreWithoutFilesystem = "SnapshotRestoreWithoutFilesystem"
 )
 
 // SupportedFS the supported FS types
 var SupportedFS = []string{"ext2", "ext3", "ext4", "xfs"}
 
 // SupportedProfile the supported profile names
-var SupportedProfile = []string{"custom", "general-purpose", "5iops-tier", "10iops-tier", "sdp"}
+var SupportedProfile = []string{CustomProfile, GeneralPurposeProfile, FiveIopsTierProfile, TenIopsTierProfile, SDPProfile}
@@ -18,7 +18,9 @@ limitations under the License.
 package ibmcsidriver
 
 import (
+	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -34,13 +36,19 @@ import (
 
 	"go.uber.org/zap"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // CSIControllerServer ...
 type CSIControllerServer struct {
 	Driver      *IBMCSIDriver
 	CSIProvider cloudProvider.CloudProviderInterface
-	mutex       utils.LockStore
+	attachQueue attachQueue
+	instances   instanceCache
+	// allowUntaggedVolumes takes the volumes not tagged with any cluster ID as owned by the cluster
+	allowUntaggedVolumes bool
 	csi.UnimplementedControllerServer
 }
 
@@ -57,8 +65,14 @@ const (
 	// PublishInfoDevicePath ...
 	PublishInfoDevicePath = "device-path"
 
+	// PublishInfoDeviceID ... ID of the device of the attachment, which the serial of the device on the node is made of
+	PublishInfoDeviceID = "device-id"
+
 	// PublishInfoRequestID ...
 	PublishInfoRequestID = "request-id"
+
+	// PublishInfoSourceSnapshotID ... ID of the snapshot the volume was restored from
+	PublishInfoSourceSnapshotID = "source-snapshot-id"
 )
 
 var _ csi.ControllerServer = &CSIControllerServer{}
@@ -89,7 +103,7 @@ func (csiCS *CSIControllerServer) CreateVolume(ctx context.Context, req *csi.Cre
 	}
 
 	// Get volume input Parameters
-	requestedVolume, err := getVolumeParameters(ctxLogger, req, csiCS.CSIProvider.GetConfig())
+	requestedVolume, err := getVolumeParameters(ctxLogger, req, csiCS.CSIProvider.GetConfig(), csiCS.Driver.region)
 	if requestedVolume != nil {
 		// For logging mask VolumeEncryptionKey
 		// Create copy of the requestedVolume
@@ -106,7 +120,11 @@ func (csiCS *CSIControllerServer) CreateVolume(ctx context.Context, req *csi.Cre
 		return nil, commonError.GetCSIError(ctxLogger, commonError.InvalidParameters, requestID, err)
 	}
 
-	// TODO: Determine Zones and Region for the disk
+	// Volume is tagged with the cluster ID at creation so that its owner is known before the PV watcher tags it
+	clusterID := csiCS.CSIProvider.GetClusterID()
+	if len(clusterID) != 0 {
+		requestedVolume.Tags = append(requestedVolume.Tags, ClusterIDLabel+":"+clusterID)
+	}
 
 	// Validate if volume Already Exists
 	session, err := csiCS.CSIProvider.GetProviderSession(ctx, ctxLogger)
@@ -120,36 +138,106 @@ func (csiCS *CSIControllerServer) CreateVolume(ctx context.Context, req *csi.Cre
 		return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
 	}
 
+	var sourceVolumeID string
 	volumeSource := req.GetVolumeContentSource()
 	if volumeSource != nil {
-		if _, ok := volumeSource.GetType().(*csi.VolumeContentSource_Snapshot); !ok {
+		switch volumeSource.GetType().(type) {
+		case *csi.VolumeContentSource_Snapshot:
+			sourceSnapshot := volumeSource.GetSnapshot()
+			if sourceSnapshot == nil {
+				return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeInvalidArguments, requestID, nil)
+			}
+			snapshotIdentifier := sourceSnapshot.GetSnapshotId()
+			// Remove all whitespaces and search crn: string at 0th position
+			// to finalise that user provided crn or not
+			if strings.Index(strings.ReplaceAll(snapshotIdentifier, " ", ""), "crn:") == 0 {
+				requestedVolume.SnapshotCRN = snapshotIdentifier
+			} else {
+				requestedVolume.SnapshotID = snapshotIdentifier
+			}
+		case *csi.VolumeContentSource_Volume:
+			sourceVolumeID = volumeSource.GetVolume().GetVolumeId()
+			if len(sourceVolumeID) == 0 {
+				return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeInvalidArguments, requestID, nil)
+			}
+		default:
 			return nil, commonError.GetCSIError(ctxLogger, commonError.UnsupportedVolumeContentSource, requestID, nil)
 		}
-		sourceSnapshot := volumeSource.GetSnapshot()
-		if sourceSnapshot == nil {
-			return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeInvalidArguments, requestID, nil)
-		}
-		snapshotIdentifier := sourceSnapshot.GetSnapshotId()
-		// Remove all whitespaces and search crn: string at 0th position
-		// to finalise that user provided crn or not
-		if strings.Index(strings.ReplaceAll(snapshotIdentifier, " ", ""), "crn:") == 0 {
-			requestedVolume.SnapshotCRN = snapshotIdentifier
-		} else {
-			requestedVolume.SnapshotID = snapshotIdentifier
-		}
 	}
 
 	existingVol, err := checkIfVolumeExists(session, *requestedVolume, ctxLogger)
+	if existingVol != nil && err == nil {
+		// Volume of the same name created by another cluster or for another request is not the one of the
+		// previous request, it is neither used nor deleted
+		if err = checkVolumeOwnership(ctxLogger, *existingVol, clusterID, csiCS.allowUntaggedVolumes); err != nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeAlreadyExists, requestID, err, *requestedVolume.Name, *requestedVolume.Capacity)
+		}
+		if err = checkVolumeRequestName(*requestedVolume, *existingVol); err != nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeAlreadyExists, requestID, err, *requestedVolume.Name, *requestedVolume.Capacity)
+		}
+		if existingVol.Status == VolumeStatusFailed {
+			// Volume of the previous request failed to create, it must not block the volume name
+			if err = deleteFailedVolume(ctxLogger, session, existingVol); err != nil {
+				return nil, commonError.GetCSIBackendError(ctxLogger, requestID, err)
+			}
+			existingVol = nil
+		}
+	}
 	if existingVol != nil && err == nil {
 		ctxLogger.Info("Volume already exists", zap.Reflect("ExistingVolume", existingVol))
-		if existingVol.Capacity != nil && requestedVolume.Capacity != nil && *existingVol.Capacity == *requestedVolume.Capacity {
-			return createCSIVolumeResponse(*existingVol, int64(*(existingVol.Capacity)*utils.GB), nil, csiCS.CSIProvider.GetClusterID(), csiCS.Driver.region), nil
+		if existingVol.Capacity == nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeAlreadyExists, requestID, err, *requestedVolume.Name, *requestedVolume.Capacity)
 		}
-		return nil, commonError.GetCSIError(ctxLogger, commonError.VolumeAlreadyExists, requestID, err, name, *requestedVolume.Capacity)
+		// Volume of the previous request has to match the request in every attribute, not only in size
+		if mismatches := getVolumeMismatches(*requestedVolume, *existingVol, len(sourceVolumeID) != 0); len(mismatches) != 0 {
+			ctxLogger.Error("Volume already exists with different attributes", zap.Strings("Mismatches", mismatches))
+			return nil, status.Errorf(codes.AlreadyExists, "volume with name '%s' already exists with different attributes: %s", *requestedVolume.Name, strings.Join(mismatches, ", "))
+		}
+		// Previous request might have returned before the volume became available
+		volumeObj, err := waitForVolumeCreation(ctx, ctxLogger, session, existingVol)
+		if err != nil {
+			return nil, getVolumeCreationError(ctxLogger, requestID, volumeObj, err)
+		}
+		if len(sourceVolumeID) != 0 {
+			// Previous request might have failed after creating the clone
+			deleteCloneSnapshot(ctxLogger, session, name)
+		}
+		return addVolumeContextParameters(createCSIVolumeResponse(*volumeObj, int64(*(existingVol.Capacity)*utils.GB), nil, clusterID, csiCS.Driver.region, volumeSource), req.GetParameters()), nil
+	}
+
+	// Clone the volume by restoring the internal snapshot of the source volume
+	if len(sourceVolumeID) != 0 {
+		sourceVolume, err := session.GetVolume(sourceVolumeID)
+		if err != nil {
+			if providerError.RetrivalFailed == providerError.GetErrorType(err) {
+				return nil, commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, err, sourceVolumeID)
+			}
+			return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+		}
+		if sourceVolume == nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, nil, sourceVolumeID)
+		}
+		if sourceVolume.Capacity != nil && *sourceVolume.Capacity > *requestedVolume.Capacity {
+			err = fmt.Errorf("requested capacity %d GiB is less than the source volume '%s' capacity %d GiB", *requestedVolume.Capacity, sourceVolumeID, *sourceVolume.Capacity)
+			return nil, commonError.GetCSIError(ctxLogger, commonError.InvalidParameters, requestID, err)
+		}
+		snapshot, err := createCloneSnapshot(ctx, ctxLogger, session, name, sourceVolumeID)
+		if err != nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+		}
+		requestedVolume.SnapshotID = snapshot.SnapshotID
 	}
 
 	// Create volume
 	volumeObj, err := session.CreateVolume(*requestedVolume)
+	if err != nil && userError.GetUserErrorCode(err) == VolumeNotInValidState {
+		// Provider gave up waiting for the volume, keep waiting for it till the request deadline
+		ctxLogger.Warn("Volume is created but not available yet", zap.Error(err))
+		volumeObj, _ = session.GetVolumeByName(*requestedVolume.Name)
+		if volumeObj != nil {
+			err = nil
+		}
+	}
 	if err != nil {
 		if providerError.RetrivalFailed == providerError.GetErrorType(err) {
 			return nil, commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, err, "creation")
@@ -157,8 +245,31 @@ func (csiCS *CSIControllerServer) CreateVolume(ctx context.Context, req *csi.Cre
 		return nil, commonError.GetCSIBackendError(ctxLogger, requestID, err)
 	}
 
+	createdVolume, err := waitForVolumeCreation(ctx, ctxLogger, session, volumeObj)
+	if err != nil {
+		return nil, getVolumeCreationError(ctxLogger, requestID, createdVolume, err)
+	}
+
+	if len(sourceVolumeID) != 0 {
+		deleteCloneSnapshot(ctxLogger, session, name)
+	}
+
 	// return csi volume object
-	return createCSIVolumeResponse(*volumeObj, int64(*(requestedVolume.Capacity)*utils.GB), nil, csiCS.CSIProvider.GetClusterID(), csiCS.Driver.region), nil
+	return addVolumeContextParameters(createCSIVolumeResponse(*createdVolume, int64(*(requestedVolume.Capacity)*utils.GB), nil, clusterID, csiCS.Driver.region, volumeSource), req.GetParameters()), nil
+}
+
+// getVolumeCreationError returns the CSI error for the volume which did not become available. If the
+// volume is still being created, a retriable error is returned so that the next attempt resumes waiting
+func getVolumeCreationError(ctxLogger *zap.Logger, requestID string, volume *provider.Volume, err error) error {
+	if volume == nil {
+		return commonError.GetCSIBackendError(ctxLogger, requestID, err)
+	}
+	switch volume.Status {
+	case VolumeStatusFailed, VolumeStatusUnusable:
+		return commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+	}
+	ctxLogger.Warn("Volume is not available yet", zap.String("VolumeID", volume.VolumeID), zap.String("Status", volume.Status), zap.Error(err))
+	return status.Errorf(codes.DeadlineExceeded, "volume '%s' is not available yet: %v", volume.VolumeID, err)
 }
 
 // DeleteVolume ...
@@ -188,11 +299,32 @@ func (csiCS *CSIControllerServer) DeleteVolume(ctx context.Context, req *csi.Del
 	volume.VolumeID = volumeID
 
 	existingVol, err := checkIfVolumeExists(session, *volume, ctxLogger)
-	if existingVol == nil && err == nil {
+	if err != nil {
+		return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+	}
+	if existingVol == nil {
 		ctxLogger.Info("Volume not found. Returning success without deletion...")
 		return &csi.DeleteVolumeResponse{}, nil
 	}
 
+	clusterID := csiCS.CSIProvider.GetClusterID()
+	if err = checkVolumeOwnership(ctxLogger, *existingVol, clusterID, csiCS.allowUntaggedVolumes); err != nil {
+		ctxLogger.Error("Volume is not owned by the cluster", zap.String("VolumeID", volumeID), zap.Error(err))
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	// Volume is deleted only once its snapshot is ready, the snapshot is kept till the retention period ends
+	if slices.Contains(existingVol.Tags, SnapshotOnDeleteTag) {
+		snapshot, err := createRetentionSnapshot(ctx, ctxLogger, session, clusterID, *existingVol)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, status.Errorf(codes.DeadlineExceeded, "snapshot of volume '%s' is not ready yet: %v", volumeID, err)
+			}
+			return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+		}
+		ctxLogger.Info("Volume snapshot is taken before deleting it", zap.String("VolumeID", volumeID), zap.String("SnapshotID", snapshot.SnapshotID))
+	}
+
 	err = session.DeleteVolume(volume)
 	if err != nil {
 		return nil, commonError.GetCSIBackendError(ctxLogger, requestID, err)
@@ -222,11 +354,18 @@ func (csiCS *CSIControllerServer) ControllerPublishVolume(ctx context.Context, r
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoVolumeCapabilities, requestID, nil)
 	}
 
-	//Allow only one active attach/detach operation for an instance at anytime
+	//Allow only one active attach/detach operation for a volume of an instance at anytime, the volumes
+	//of the instance are attached at the same time so that the waits for the attachments overlap
 	lockWaitStart := time.Now()
-	csiCS.mutex.Lock(nodeID)
-	defer csiCS.mutex.Unlock(nodeID)
+	ctxLogger.Info("Queueing attach", zap.String("NodeID", nodeID), zap.Int("QueueDepth", csiCS.attachQueue.depth(nodeID)))
+	release, err := csiCS.attachQueue.acquire(ctx, nodeID, volumeID)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	defer release()
+	// Lock duration covers the wait and the attach as before, the wait alone is recorded as queue wait
 	defer metrics.UpdateDurationFromStart(ctxLogger, metrics.FunctionLabel("ControllerPublishVolume.Lock"), lockWaitStart)
+	metrics.UpdateDurationFromStart(ctxLogger, metrics.FunctionLabel("ControllerPublishVolume.QueueWait"), lockWaitStart)
 
 	volumeCapabilities := []*csi.VolumeCapability{volumeCapability}
 	// Validate volume capabilities, are all capabilities supported by driver or not
@@ -240,7 +379,15 @@ func (csiCS *CSIControllerServer) ControllerPublishVolume(ctx context.Context, r
 	}
 
 	// Validate the node instance that the volume will be attached to actually exists
-	// Todo Need and API to check existence of an instance being attached to via Lib
+	instanceFound, err := csiCS.instances.check(ctxLogger, sess, volumeID, nodeID)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "instance '%s' could not be looked up: %v", nodeID, err)
+	}
+	if !instanceFound {
+		ctxLogger.Warn("Instance is not found, skipping attach", zap.String("NodeID", nodeID))
+		return nil, commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, fmt.Errorf("instance '%s' is not found", nodeID))
+	}
+
 	requestedVolume := &provider.Volume{}
 	requestedVolume.VolumeID = volumeID
 	volDetail, err := checkIfVolumeExists(sess, *requestedVolume, ctxLogger)
@@ -280,7 +427,12 @@ func (csiCS *CSIControllerServer) ControllerPublishVolume(ctx context.Context, r
 	}
 
 	ctxLogger.Info("Attachment response", zap.Reflect("Response", response))
-	controllerPublishVolumeResponse := createControllerPublishVolumeResponse(*response, map[string]string{PublishInfoRequestID: requestID})
+	extraPublishInfo := map[string]string{PublishInfoRequestID: requestID}
+	// Node refuses to format the volume restored from a snapshot if it has no filesystem
+	if len(volDetail.SnapshotID) != 0 {
+		extraPublishInfo[PublishInfoSourceSnapshotID] = volDetail.SnapshotID
+	}
+	controllerPublishVolumeResponse := createControllerPublishVolumeResponse(*response, extraPublishInfo)
 	return controllerPublishVolumeResponse, nil
 }
 
@@ -302,9 +454,15 @@ func (csiCS *CSIControllerServer) ControllerUnpublishVolume(ctx context.Context,
 		return nil, commonError.GetCSIError(ctxLogger, commonError.EmptyNodeID, requestID, nil)
 	}
 
-	//Allow only one active attach/detach operation for an instance at anytime
-	csiCS.mutex.Lock(nodeID)
-	defer csiCS.mutex.Unlock(nodeID)
+	//Allow only one active attach/detach operation for a volume of an instance at anytime
+	lockWaitStart := time.Now()
+	ctxLogger.Info("Queueing detach", zap.String("NodeID", nodeID), zap.Int("QueueDepth", csiCS.attachQueue.depth(nodeID)))
+	release, err := csiCS.attachQueue.acquire(ctx, nodeID, volumeID)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	defer release()
+	metrics.UpdateDurationFromStart(ctxLogger, metrics.FunctionLabel("ControllerUnpublishVolume.QueueWait"), lockWaitStart)
 
 	clusterID := csiCS.CSIProvider.GetClusterID()
 	volumeAttachmentReq := provider.VolumeAttachmentRequest{
@@ -389,6 +547,7 @@ func (csiCS *CSIControllerServer) ListVolumes(ctx context.Context, req *csi.List
 	}
 
 	maxEntries := int(req.MaxEntries)
+	clusterID := csiCS.CSIProvider.GetClusterID()
 	tags := map[string]string{}
 	volumeList, err := session.ListVolumes(maxEntries, req.StartingToken, tags)
 	if err != nil {
@@ -403,13 +562,12 @@ func (csiCS *CSIControllerServer) ListVolumes(ctx context.Context, req *csi.List
 
 	entries := []*csi.ListVolumesResponse_Entry{}
 	for _, vol := range volumeList.Volumes {
+		// Volumes of other clusters of the account are not listed
+		if isVolumeOfOtherCluster(*vol, clusterID) {
+			continue
+		}
 		if vol.Capacity != nil {
-			entries = append(entries, &csi.ListVolumesResponse_Entry{
-				Volume: &csi.Volume{
-					VolumeId:      vol.VolumeID,
-					CapacityBytes: int64(*vol.Capacity * utils.GiB),
-				},
-			})
+			entries = append(entries, createListVolumesResponseEntry(*vol, csiCS.Driver.region))
 		}
 	}
 
@@ -423,10 +581,42 @@ func (csiCS *CSIControllerServer) ListVolumes(ctx context.Context, req *csi.List
 func (csiCS *CSIControllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
 	ctxLogger, requestID := utils.GetContextLogger(ctx, false)
 	// populate requestID in the context
-	_ = context.WithValue(ctx, provider.RequestID, requestID)
-
+	ctx = context.WithValue(ctx, provider.RequestID, requestID)
 	ctxLogger.Info("CSIControllerServer-GetCapacity", zap.Reflect("Request", req))
-	return nil, commonError.GetCSIError(ctxLogger, commonError.MethodUnimplemented, requestID, nil, "GetCapacity")
+	defer metrics.UpdateDurationFromStart(ctxLogger, metrics.FunctionLabel("GetCapacity"), time.Now())
+
+	var profileName string
+	if value, ok := req.GetParameters()[Profile]; ok {
+		profile, err := parseProfile(Profile, value)
+		if err != nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.InvalidParameters, requestID, err)
+		}
+		profileName = profile.Name
+	}
+	minVolumeSize, maxVolumeSize := getVolumeSizeLimits(profileName)
+
+	volumeCountLimit, capacityLimit := getZoneLimits(ctxLogger)
+	zone := req.GetAccessibleTopology().GetSegments()[utils.NodeZoneLabel]
+	var volumeCount, capacityUsed int64
+	// Limits are tracked per zone, nothing to check if limits are not configured or the zone is not known
+	if len(zone) != 0 && (volumeCountLimit > 0 || capacityLimit > 0) {
+		session, err := csiCS.CSIProvider.GetProviderSession(ctx, ctxLogger)
+		if err != nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+		}
+		volumeCount, capacityUsed, err = getZoneUsage(session, zone)
+		if err != nil {
+			return nil, commonError.GetCSIBackendError(ctxLogger, requestID, err)
+		}
+		ctxLogger.Info("Zone usage", zap.String("Zone", zone), zap.Int64("VolumeCount", volumeCount), zap.Int64("CapacityGiB", capacityUsed))
+	}
+
+	availableCapacity, maxSize := getAvailableCapacity(maxVolumeSize, volumeCountLimit, capacityLimit, volumeCount, capacityUsed)
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: availableCapacity,
+		MaximumVolumeSize: wrapperspb.Int64(maxSize),
+		MinimumVolumeSize: wrapperspb.Int64(minVolumeSize),
+	}, nil
 }
 
 // ControllerGetCapabilities implements the default GRPC callout.
@@ -435,7 +625,7 @@ func (csiCS *CSIControllerServer) ControllerGetCapabilities(ctx context.Context,
 	// populate requestID in the context
 	_ = context.WithValue(ctx, provider.RequestID, requestID)
 
-	ctxLogger.Info("CSIControllerServer-GetCapacity", zap.Reflect("Request", req))
+	ctxLogger.Info("CSIControllerServer-ControllerGetCapabilities", zap.Reflect("Request", req))
 	// Return the capabilities as per provider volume capabilities
 	return &csi.ControllerGetCapabilitiesResponse{
 		Capabilities: csiCS.Driver.cscap,
@@ -453,7 +643,6 @@ func (csiCS *CSIControllerServer) CreateSnapshot(ctx context.Context, req *csi.C
 	//Feature flag to enable/disable CreateSnapshot feature.
 	if strings.ToLower(os.Getenv("IS_SNAPSHOT_ENABLED")) == "false" {
 		ctxLogger.Warn("CreateSnapshot functionality is disabled.")
-		time.Sleep(10 * time.Minute) //To avoid multiple retries from kubernetes to CSI Driver
 		return nil, commonError.GetCSIError(ctxLogger, commonError.MethodUnimplemented, requestID, nil, "CreateSnapshot functionality is disabled.")
 	}
 
@@ -467,6 +656,12 @@ func (csiCS *CSIControllerServer) CreateSnapshot(ctx context.Context, req *csi.C
 		return nil, commonError.GetCSIError(ctxLogger, commonError.MissingSourceVolumeID, requestID, nil)
 	}
 
+	// Get snapshot input Parameters
+	snapshotParameters, err := getSnapshotParameters(ctxLogger, req)
+	if err != nil {
+		return nil, commonError.GetCSIError(ctxLogger, commonError.InvalidParameters, requestID, err)
+	}
+
 	// Validate if volume Already Exists
 	session, err := csiCS.CSIProvider.GetProviderSession(ctx, ctxLogger)
 	if err != nil {
@@ -487,22 +682,32 @@ func (csiCS *CSIControllerServer) CreateSnapshot(ctx context.Context, req *csi.C
 		ctxLogger.Info("Snapshot with name already exist for volume", zap.Reflect("SnapshotName", snapshotName), zap.Reflect("VolumeID", sourceVolumeID))
 		return createCSISnapshotResponse(*snapshot), nil
 	}
-	snapshotParameters := provider.SnapshotParameters{}
-	snapshotParameters.Name = snapshotName
-	snapshotTags := map[string]string{
-		"name": snapshotName,
-	}
-	snapshotParameters.SnapshotTags = snapshotTags
-
+	// Snapshot is returned without waiting for it to be ready, snapshotter keeps polling till it is ready
 	snapshot, err = session.CreateSnapshot(sourceVolumeID, snapshotParameters)
-
 	if err != nil {
-		time.Sleep(time.Duration(getMaxDelaySnapshotCreate(ctxLogger)) * time.Second) //To avoid multiple retries from kubernetes to CSI Driver
-		return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err, "creation")
+		return nil, getSnapshotCreationError(ctxLogger, requestID, err, sourceVolumeID)
 	}
 	return createCSISnapshotResponse(*snapshot), nil
 }
 
+// getSnapshotCreationError maps the snapshot creation failure to the CSI error. Server side and throttling
+// failures are returned as Unavailable, so that the snapshotter retries them with backoff
+func getSnapshotCreationError(ctxLogger *zap.Logger, requestID string, err error, sourceVolumeID string) error {
+	if providerError.GetErrorType(err) == providerError.RetrivalFailed {
+		return commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, err, sourceVolumeID)
+	}
+	backendError := strings.ReplaceAll(err.Error(), " ", "")
+	if !strings.Contains(backendError, commonError.RC5XX) && !strings.Contains(backendError, RC429) {
+		return commonError.GetCSIBackendError(ctxLogger, requestID, err)
+	}
+	userMsg := commonError.GetCSIMessage(commonError.InternalError)
+	userMsg.Type = codes.Unavailable
+	userMsg.RequestID = requestID
+	userMsg.BackendError = err.Error()
+	ctxLogger.Error("FAILED BACKEND ERROR, snapshot creation will be retried", zap.Error(userMsg))
+	return status.Error(userMsg.Type, userMsg.Info())
+}
+
 // DeleteSnapshot ...
 func (csiCS *CSIControllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
 	ctxLogger, requestID := utils.GetContextLogger(ctx, false)
@@ -532,6 +737,17 @@ func (csiCS *CSIControllerServer) DeleteSnapshot(ctx context.Context, req *csi.D
 	snapshot := &provider.Snapshot{}
 	snapshot.SnapshotID, _ = getSnapshotAndAccountIDsFromCRN(snapshotID)
 
+	// Provider does not return the tags of the snapshots, the ownership is checked on the source volume
+	// if it still exists
+	if existingSnapshot, err := session.GetSnapshot(snapshot.SnapshotID); err == nil && existingSnapshot != nil && len(existingSnapshot.VolumeID) != 0 {
+		if sourceVolume, err := session.GetVolume(existingSnapshot.VolumeID); err == nil && sourceVolume != nil {
+			if err = checkVolumeOwnership(ctxLogger, *sourceVolume, csiCS.CSIProvider.GetClusterID(), csiCS.allowUntaggedVolumes); err != nil {
+				ctxLogger.Error("Source volume of the snapshot is not owned by the cluster", zap.String("SnapshotID", snapshotID), zap.Error(err))
+				return nil, status.Errorf(codes.FailedPrecondition, "snapshot '%s' can not be deleted: %v", snapshotID, err)
+			}
+		}
+	}
+
 	err = session.DeleteSnapshot(snapshot)
 	if err != nil {
 		if providerError.RetrivalFailed == providerError.GetErrorType(err) {
@@ -674,6 +890,15 @@ func (csiCS *CSIControllerServer) ControllerExpandVolume(ctx context.Context, re
 		return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
 	}
 
+	if err = checkVolumeOwnership(ctxLogger, *volDetail, csiCS.CSIProvider.GetClusterID(), csiCS.allowUntaggedVolumes); err != nil {
+		ctxLogger.Error("Volume is not owned by the cluster", zap.String("VolumeID", volumeID), zap.Error(err))
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if err = validateVolumeExpansion(volDetail, capacity); err != nil {
+		return nil, commonError.GetCSIError(ctxLogger, commonError.InvalidParameters, requestID, err)
+	}
+
 	volumeExpansionReq := provider.ExpandVolumeRequest{
 		VolumeID: volumeID,
 		Capacity: capacity,
@@ -685,10 +910,39 @@ func (csiCS *CSIControllerServer) ControllerExpandVolume(ctx context.Context, re
 	return &csi.ControllerExpandVolumeResponse{CapacityBytes: capacity, NodeExpansionRequired: true}, nil
 }
 
-// ControllerGetVolume ...
+// ControllerGetVolume returns the volume with its condition, which is abnormal if the volume status is failed,
+// unusable or pending deletion
 func (csiCS *CSIControllerServer) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (*csi.ControllerGetVolumeResponse, error) {
 	ctxLogger, requestID := utils.GetContextLogger(ctx, false)
-	return nil, commonError.GetCSIError(ctxLogger, commonError.MethodUnimplemented, requestID, nil, "ControllerGetVolume")
+	// populate requestID in the context
+	ctx = context.WithValue(ctx, provider.RequestID, requestID)
+	ctxLogger.Info("CSIControllerServer-ControllerGetVolume...", zap.Reflect("Request", req))
+	defer metrics.UpdateDurationFromStart(ctxLogger, metrics.FunctionLabel("ControllerGetVolume"), time.Now())
+
+	volumeID := req.GetVolumeId()
+	if len(volumeID) == 0 {
+		return nil, commonError.GetCSIError(ctxLogger, commonError.EmptyVolumeID, requestID, nil)
+	}
+
+	session, err := csiCS.CSIProvider.GetProviderSession(ctx, ctxLogger)
+	if err != nil {
+		return nil, commonError.GetCSIError(ctxLogger, commonError.InternalError, requestID, err)
+	}
+
+	// Get volume details by using volume ID, it should exists with provider
+	volume, err := session.GetVolume(volumeID)
+	if err != nil {
+		errorType := providerError.GetErrorType(err)
+		if errorType == providerError.RetrivalFailed || errorType == providerError.EntityNotFound {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, err, volumeID)
+		}
+		return nil, commonError.GetCSIBackendError(ctxLogger, requestID, err)
+	}
+	if volume == nil {
+		return nil, commonError.GetCSIError(ctxLogger, commonError.ObjectNotFound, requestID, nil, volumeID)
+	}
+
+	return createControllerGetVolumeResponse(*volume), nil
 }
 
 // ControllerModifyVolume ...
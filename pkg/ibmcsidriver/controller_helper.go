@@ -19,19 +19,33 @@ package ibmcsidriver
 
 import (
 	"fmt"
+	"hash/fnv"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/IBM/ibm-csi-common/pkg/utils"
 	"github.com/IBM/ibmcloud-volume-interface/config"
 	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
 	providerError "github.com/IBM/ibmcloud-volume-interface/lib/utils"
+	"github.com/IBM/ibmcloud-volume-vpc/common/vpcclient/models"
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"go.uber.org/zap"
+	"golang.org/x/net/context"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+var (
+	// volumeStatusPollInterval is the interval between two volume status checks
+	volumeStatusPollInterval = 5 * time.Second
+
+	// volumeStatusWaitTimeout is the max time to wait for volume status change if the request has no deadline
+	volumeStatusWaitTimeout = 10 * time.Minute
+)
+
 // normalize the requested capacity(in GiB) to what is supported by the driver
 func getRequestedCapacity(capRange *csi.CapacityRange, profileName string) (int64, error) {
 	// Input is in bytes from csi
@@ -99,25 +113,51 @@ func areVolumeCapabilitiesSupported(volCaps []*csi.VolumeCapability, driverVolum
 
 // getVolumeParameters this function get the parameters from storage class, this also validate
 // all parameters passed in storage class or not which are mandatory.
-func getVolumeParameters(logger *zap.Logger, req *csi.CreateVolumeRequest, config *config.Config) (*provider.Volume, error) {
+func getVolumeParameters(logger *zap.Logger, req *csi.CreateVolumeRequest, config *config.Config, region string) (*provider.Volume, error) {
 	var encrypt = "undef"
+	var shared = false
+	var snapshotOnDelete = false
 	var err error
+	var allowedZones []string
+	var nameTemplate, tagTemplate string
+	metadata := map[string]string{}
 	volume := &provider.Volume{}
 	volume.Name = &req.Name
 	for key, value := range req.GetParameters() {
 		switch key {
 		case Profile:
-			if utils.ListContainsSubstr(SupportedProfile, value) {
-				volume.Profile = &provider.Profile{Name: value}
-			} else {
-				err = fmt.Errorf("%s:<%v> unsupported profile. Supported profiles are: %v", key, value, SupportedProfile)
-			}
+			volume.Profile, err = parseProfile(key, value)
 		case Zone:
 			if len(value) > ZoneNameMaxLen {
 				err = fmt.Errorf("%s:<%v> exceeds %d chars", key, value, ZoneNameMaxLen)
 			} else {
 				volume.Az = value
 			}
+		case Zones:
+			allowedZones, err = parseZones(key, value)
+		case SharedVolume:
+			if value != TrueStr && value != FalseStr {
+				err = fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", value, key)
+			} else {
+				shared = value == TrueStr
+			}
+		case SnapshotOnDelete:
+			if value != TrueStr && value != FalseStr {
+				err = fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", value, key)
+			} else {
+				snapshotOnDelete = value == TrueStr
+			}
+		case FormatRestoredVolume:
+			// Passed to the node in the volume context
+			if value != TrueStr && value != FalseStr {
+				err = fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", value, key)
+			}
+		case PVCNameKey, PVCNamespaceKey, PVNameKey:
+			metadata[key] = value
+		case NameTemplate:
+			nameTemplate = value
+		case TagTemplate:
+			tagTemplate = value
 		case Region:
 			if len(value) > RegionMaxLen {
 				err = fmt.Errorf("%s:<%v> exceeds %d chars", key, value, RegionMaxLen)
@@ -170,12 +210,7 @@ func getVolumeParameters(logger *zap.Logger, req *csi.CreateVolumeRequest, confi
 			}
 		case Throughput: // getting throughput value from storage class if it is provided
 			if len(value) != 0 {
-				bandwidth, errParse := strconv.ParseInt(value, 10, 32)
-				if errParse != nil {
-					err = fmt.Errorf("'<%v>' is invalid, value of '%s' should be an int32 type", value, key)
-				} else {
-					volume.Bandwidth = int32(bandwidth)
-				}
+				volume.Bandwidth, err = parseThroughput(key, value)
 			}
 		default:
 			err = fmt.Errorf("<%s> is an invalid parameter", key)
@@ -185,11 +220,40 @@ func getVolumeParameters(logger *zap.Logger, req *csi.CreateVolumeRequest, confi
 			return volume, err
 		}
 	}
+
 	// If encripted is set to false
 	if encrypt == FalseStr {
 		volume.VolumeEncryptionKey = nil
 	}
 
+	// Templates are resolved once all the parameters are read as they refer to the PVC metadata
+	if len(nameTemplate) != 0 {
+		var name string
+		if name, err = resolveVolumeName(nameTemplate, metadata); err != nil {
+			logger.Error("getVolumeParameters", zap.NamedError("InvalidParameter", err))
+			return volume, err
+		}
+		volume.Name = &name
+		// Templated names of different requests can be the same, the request is recorded to tell them apart
+		volume.Tags = append(volume.Tags, RequestNameLabel+":"+req.GetName())
+	}
+	if len(tagTemplate) != 0 {
+		var tags []string
+		if tags, err = resolveVolumeTags(tagTemplate, metadata); err != nil {
+			logger.Error("getVolumeParameters", zap.NamedError("InvalidParameter", err))
+			return volume, err
+		}
+		volume.Tags = append(volume.Tags, tags...)
+	}
+
+	// Opt-in is kept on the volume as DeleteVolume does not get the storage class parameters
+	if shared {
+		volume.Tags = append(volume.Tags, SharedVolumeTag)
+	}
+	if snapshotOnDelete {
+		volume.Tags = append(volume.Tags, SnapshotOnDeleteTag)
+	}
+
 	if volume.Profile == nil {
 		err = fmt.Errorf("volume profile is empty, you need to pass valid profile name")
 		logger.Error("getVolumeParameters", zap.NamedError("InvalidRequest", err))
@@ -252,21 +316,405 @@ func getVolumeParameters(logger *zap.Logger, req *csi.CreateVolumeRequest, confi
 		volume.Iops = nil
 	}
 
+	if err = validateProfileCapabilities(volume); err != nil {
+		logger.Error("getVolumeParameters", zap.NamedError("InvalidParameter", err))
+		return volume, err
+	}
+
+	// Zone has to be of the region passed in storage class, or else of the region of the driver
+	zoneRegion := region
+	if len(volume.Region) != 0 {
+		zoneRegion = volume.Region
+	}
 	//If  zone not provided in storage class parameters then we pick from the Topology
 	if len(strings.TrimSpace(volume.Az)) == 0 {
-		zones, err := pickTargetTopologyParams(req.GetAccessibilityRequirements())
+		zones, err := pickTargetTopologyParams(req.GetAccessibilityRequirements(), allowedZones, region, req.GetName())
 		if err != nil {
 			err = fmt.Errorf("unable to fetch zone information from topology: '%v'", err)
 			logger.Error("getVolumeParameters", zap.NamedError("InvalidParameter", err))
 			return volume, err
 		}
 		volume.Az = zones[utils.NodeZoneLabel]
-
+	} else if !isZoneInRegion(volume.Az, zoneRegion) {
+		err = fmt.Errorf("%s:<%v> is not a zone of the region '%s'", Zone, volume.Az, zoneRegion)
+		logger.Error("getVolumeParameters", zap.NamedError("InvalidParameter", err))
+		return volume, err
+	} else if len(allowedZones) != 0 && !slices.Contains(allowedZones, volume.Az) {
+		err = fmt.Errorf("%s:<%v> is not one of the allowed %s %v", Zone, volume.Az, Zones, allowedZones)
+		logger.Error("getVolumeParameters", zap.NamedError("InvalidParameter", err))
+		return volume, err
 	}
 
 	return volume, nil
 }
 
+// parseZones validates the comma separated zones allowlist passed in storage class
+func parseZones(key string, value string) ([]string, error) {
+	var zones []string
+	for _, zone := range strings.Split(value, ",") {
+		zone = strings.TrimSpace(zone)
+		if len(zone) == 0 {
+			continue
+		}
+		if len(zone) > ZoneNameMaxLen {
+			return nil, fmt.Errorf("%s:<%v> exceeds %d chars", key, zone, ZoneNameMaxLen)
+		}
+		if !slices.Contains(zones, zone) {
+			zones = append(zones, zone)
+		}
+	}
+	return zones, nil
+}
+
+// templateVariables maps the template variables to the PVC metadata keys
+var templateVariables = map[string]string{
+	"pvc.name":      PVCNameKey,
+	"pvc.namespace": PVCNamespaceKey,
+	"pv.name":       PVNameKey,
+}
+
+var templateVariableRegex = regexp.MustCompile(`\$\{[^}]*\}`)
+
+var volumeNameRegex = regexp.MustCompile(`^([a-z]|[a-z][-a-z0-9]*[a-z0-9])$`)
+
+// resolveTemplate replaces the ${pvc.name}, ${pvc.namespace} and ${pv.name} variables of the template
+// with the PVC metadata passed by provisioner
+func resolveTemplate(key string, template string, metadata map[string]string) (string, error) {
+	var err error
+	resolved := templateVariableRegex.ReplaceAllStringFunc(template, func(variable string) string {
+		metadataKey, ok := templateVariables[strings.TrimSpace(variable[2:len(variable)-1])]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("%s:<%v> has unknown variable '%s'. Supported variables are: %v", key, template, variable, []string{"${pvc.name}", "${pvc.namespace}", "${pv.name}"})
+			}
+			return variable
+		}
+		value, ok := metadata[metadataKey]
+		if !ok && err == nil {
+			err = fmt.Errorf("%s:<%v> needs '%s' which is passed only if csi-provisioner runs with --extra-create-metadata", key, template, metadataKey)
+		}
+		return value
+	})
+	return resolved, err
+}
+
+// resolveVolumeName resolves the volume name template, the resolved name has to be a valid VPC volume name
+func resolveVolumeName(template string, metadata map[string]string) (string, error) {
+	name, err := resolveTemplate(NameTemplate, template, metadata)
+	if err != nil {
+		return "", err
+	}
+	if len(name) > VolumeNameMaxLen || !volumeNameRegex.MatchString(name) {
+		return "", fmt.Errorf("%s:<%v> resolves to '%s' which is not a valid volume name, it should be at most %d chars of lowercase letters, digits and hyphens starting with a letter",
+			NameTemplate, template, name, VolumeNameMaxLen)
+	}
+	return name, nil
+}
+
+// resolveVolumeTags resolves the comma separated volume tags template
+func resolveVolumeTags(template string, metadata map[string]string) ([]string, error) {
+	var tags []string
+	for _, tagTemplate := range strings.Split(template, ",") {
+		tag, err := resolveTemplate(TagTemplate, strings.TrimSpace(tagTemplate), metadata)
+		if err != nil {
+			return nil, err
+		}
+		if len(tag) == 0 {
+			continue
+		}
+		if len(tag) > TagMaxLen {
+			return nil, fmt.Errorf("%s:<%v> exceeds %d chars", TagTemplate, tag, TagMaxLen)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// getSnapshotParameters gets the snapshot parameters from volume snapshot class. Provider library creates the
+// snapshot with its name only, the tags, resource group and encryption are not sent, so they are rejected
+// instead of being silently dropped.
+func getSnapshotParameters(logger *zap.Logger, req *csi.CreateSnapshotRequest) (provider.SnapshotParameters, error) {
+	snapshotParameters := provider.SnapshotParameters{
+		Name:         req.GetName(),
+		SnapshotTags: provider.SnapshotTags{"name": req.GetName()},
+	}
+
+	var err error
+	for key, value := range req.GetParameters() {
+		switch key {
+		case VolumeSnapshotNameKey, VolumeSnapshotNamespaceKey, VolumeSnapshotContentNameKey:
+			// Passed by snapshotter with --extra-create-metadata, nothing to do as snapshots are not tagged
+			logger.Debug("Ignoring snapshot metadata", zap.String(key, value))
+		case Tag, ResourceGroup, Encrypted, EncryptionKey:
+			// Snapshot is created untagged in the resource group of the driver with the encryption of the source volume
+			err = fmt.Errorf("<%s> is not supported for snapshots", key)
+		case CopyToRegions:
+			// Provider library can not copy snapshots across regions yet. A snapshot copied by other means
+			// can still be restored in the other region by passing its CRN as the snapshot handle
+			err = fmt.Errorf("<%s> is not supported, snapshots can not be copied to other regions by the driver", key)
+		case FastRestoreZones:
+			// Provider library can not enable fast restore on snapshots yet
+			err = fmt.Errorf("<%s> is not supported, fast restore can not be enabled on snapshots by the driver", key)
+		default:
+			err = fmt.Errorf("<%s> is an invalid parameter", key)
+		}
+		if err != nil {
+			logger.Error("getSnapshotParameters", zap.NamedError("SnapshotClass Parameters", err))
+			return snapshotParameters, err
+		}
+	}
+
+	for key := range req.GetSecrets() {
+		switch key {
+		case Tag:
+			err = fmt.Errorf("<%s> is not supported for snapshots", key)
+		default:
+			err = fmt.Errorf("<%s> is an invalid parameter", key)
+		}
+		if err != nil {
+			logger.Error("getSnapshotParameters", zap.NamedError("Secret Parameters", err))
+			return snapshotParameters, err
+		}
+	}
+	return snapshotParameters, nil
+}
+
+// parseProfile validates the profile name passed in storage class
+func parseProfile(key string, value string) (*provider.Profile, error) {
+	if !utils.ListContainsSubstr(SupportedProfile, value) {
+		return nil, fmt.Errorf("%s:<%v> unsupported profile. Supported profiles are: %v", key, value, SupportedProfile)
+	}
+	return &provider.Profile{Name: value}, nil
+}
+
+// parseThroughput validates the throughput(bandwidth) passed in storage class
+func parseThroughput(key string, value string) (int32, error) {
+	bandwidth, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("'<%v>' is invalid, value of '%s' should be an int32 type", value, key)
+	}
+	return int32(bandwidth), nil
+}
+
+// contextWithDefaultTimeout returns the context with the default wait timeout if the context has no deadline
+func contextWithDefaultTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, volumeStatusWaitTimeout)
+}
+
+// waitForVolumeAvailable polls the volume till it is available, goes into failed state or the context expires
+func waitForVolumeAvailable(ctx context.Context, logger *zap.Logger, session provider.Session, volumeID string) (*provider.Volume, error) {
+	ctx, cancel := contextWithDefaultTimeout(ctx)
+	defer cancel()
+	for {
+		volume, err := session.GetVolume(volumeID)
+		if err != nil {
+			return nil, err
+		}
+		if volume == nil {
+			return nil, fmt.Errorf("volume '%s' not found", volumeID)
+		}
+		switch volume.Status {
+		case VolumeStatusAvailable:
+			return volume, nil
+		case VolumeStatusFailed, VolumeStatusUnusable:
+			return volume, fmt.Errorf("volume '%s' is in '%s' state", volumeID, volume.Status)
+		}
+		logger.Info("Waiting for volume to be available", zap.String("VolumeID", volumeID), zap.String("Status", volume.Status))
+		select {
+		case <-ctx.Done():
+			return volume, fmt.Errorf("volume '%s' is in '%s' state: %v", volumeID, volume.Status, ctx.Err())
+		case <-time.After(volumeStatusPollInterval):
+		}
+	}
+}
+
+// getCloneSnapshotName returns the name of the internal snapshot which is used to clone the volume
+func getCloneSnapshotName(volumeName string) string {
+	return CloneSnapshotPrefix + volumeName
+}
+
+// createCloneSnapshot takes the internal snapshot of the source volume and waits till it is ready to use.
+// The snapshot name is derived from the volume name so that a retried request reuses the same snapshot
+func createCloneSnapshot(ctx context.Context, logger *zap.Logger, session provider.Session, volumeName string, sourceVolumeID string) (*provider.Snapshot, error) {
+	snapshotName := getCloneSnapshotName(volumeName)
+	snapshotParameters := provider.SnapshotParameters{
+		Name:         snapshotName,
+		SnapshotTags: map[string]string{"name": snapshotName},
+	}
+	return createSnapshotAndWait(ctx, logger, session, sourceVolumeID, snapshotParameters)
+}
+
+// createSnapshotAndWait creates the snapshot of the volume, unless the snapshot of the same name already
+// exists for it, and waits till it is ready to use
+func createSnapshotAndWait(ctx context.Context, logger *zap.Logger, session provider.Session, sourceVolumeID string, snapshotParameters provider.SnapshotParameters) (*provider.Snapshot, error) {
+	snapshotName := snapshotParameters.Name
+	snapshot, err := getSnapshotByName(session, snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		if snapshot.VolumeID != sourceVolumeID {
+			return nil, fmt.Errorf("snapshot '%s' already exists for volume '%s'", snapshotName, snapshot.VolumeID)
+		}
+		logger.Info("Snapshot already exists", zap.String("SnapshotName", snapshotName), zap.String("SnapshotID", snapshot.SnapshotID))
+	} else {
+		snapshot, err = session.CreateSnapshot(sourceVolumeID, snapshotParameters)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Snapshot created", zap.String("SnapshotName", snapshotName), zap.String("SnapshotID", snapshot.SnapshotID))
+	}
+	return waitForSnapshotReady(ctx, logger, session, snapshot)
+}
+
+// getSnapshotByName returns the snapshot of the name, or nil if the snapshot is not found
+func getSnapshotByName(session provider.Session, snapshotName string) (*provider.Snapshot, error) {
+	snapshot, err := session.GetSnapshotByName(snapshotName)
+	if err != nil {
+		if providerError.GetErrorType(err) == providerError.RetrivalFailed {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// deleteCloneSnapshot deletes the internal snapshot once the cloned volume is created. Failure is only logged
+// as the clone is already usable
+func deleteCloneSnapshot(logger *zap.Logger, session provider.Session, volumeName string) {
+	snapshotName := getCloneSnapshotName(volumeName)
+	snapshot, err := getSnapshotByName(session, snapshotName)
+	if err != nil {
+		logger.Warn("Failed to find clone snapshot", zap.String("SnapshotName", snapshotName), zap.Error(err))
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+	if err := session.DeleteSnapshot(snapshot); err != nil {
+		logger.Warn("Failed to delete clone snapshot", zap.String("SnapshotName", snapshotName), zap.String("SnapshotID", snapshot.SnapshotID), zap.Error(err))
+		return
+	}
+	logger.Info("Clone snapshot deleted", zap.String("SnapshotName", snapshotName), zap.String("SnapshotID", snapshot.SnapshotID))
+}
+
+// getVolumeClusterIDs returns the IDs of the clusters the volume is tagged with
+func getVolumeClusterIDs(volume provider.Volume) []string {
+	var clusterIDs []string
+	prefix := strings.ToLower(ClusterIDLabel) + ":"
+	for _, tag := range volume.Tags {
+		if clusterID, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(tag)), prefix); ok && len(clusterID) != 0 {
+			clusterIDs = append(clusterIDs, clusterID)
+		}
+	}
+	return clusterIDs
+}
+
+// isVolumeOfOtherCluster checks the volume is tagged with the ID of other clusters only. Volumes tagged as
+// shared and volumes not tagged with any cluster ID, like the volumes created before the tagging, are not
+// taken as of other clusters
+func isVolumeOfOtherCluster(volume provider.Volume, clusterID string) bool {
+	if len(clusterID) == 0 || slices.Contains(volume.Tags, SharedVolumeTag) {
+		return false
+	}
+	owners := getVolumeClusterIDs(volume)
+	return len(owners) != 0 && !slices.Contains(owners, strings.ToLower(clusterID))
+}
+
+// getVolumeRequestName returns the request name the volume is tagged with, empty if the volume is not named
+// by a name template
+func getVolumeRequestName(volume provider.Volume) string {
+	prefix := strings.ToLower(RequestNameLabel) + ":"
+	for _, tag := range volume.Tags {
+		if requestName, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(tag)), prefix); ok {
+			return requestName
+		}
+	}
+	return ""
+}
+
+// checkVolumeRequestName checks the volume named by a name template is created for the same request, the
+// same template resolves to the same name for the PVC which is deleted and created again
+func checkVolumeRequestName(requested provider.Volume, existing provider.Volume) error {
+	requestName := getVolumeRequestName(requested)
+	if len(requestName) != 0 && requestName != getVolumeRequestName(existing) {
+		return fmt.Errorf("volume '%s' is created for the request '%s', not for the request '%s'", existing.VolumeID, getVolumeRequestName(existing), requestName)
+	}
+	return nil
+}
+
+// checkVolumeOwnership checks the volume is owned by the cluster i.e it is tagged with the cluster ID by the
+// driver or by the PV watcher. Volumes tagged as shared can be managed by any cluster, and nothing is
+// checked if the cluster ID is not known. Volumes not tagged with any cluster ID, like the volumes created
+// before the tagging or of the clusters without the PV watcher, are owned only if allowUntagged is set.
+func checkVolumeOwnership(logger *zap.Logger, volume provider.Volume, clusterID string, allowUntagged bool) error {
+	if len(clusterID) == 0 || slices.Contains(volume.Tags, SharedVolumeTag) {
+		return nil
+	}
+	owners := getVolumeClusterIDs(volume)
+	if len(owners) == 0 {
+		if !allowUntagged {
+			return fmt.Errorf("volume '%s' is not tagged with the ID of any cluster. Tag it with '%s:%s' or '%s', or set ALLOW_UNTAGGED_VOLUMES to allow it",
+				volume.VolumeID, ClusterIDLabel, clusterID, SharedVolumeTag)
+		}
+		logger.Warn("Volume is not tagged with the ID of any cluster, taking it as owned by the cluster",
+			zap.String("VolumeID", volume.VolumeID), zap.String("ClusterID", clusterID))
+		return nil
+	}
+	if isVolumeOfOtherCluster(volume, clusterID) {
+		return fmt.Errorf("volume '%s' is owned by the clusters %v, not by the cluster '%s'. Tag it with '%s' to allow it",
+			volume.VolumeID, owners, clusterID, SharedVolumeTag)
+	}
+	return nil
+}
+
+// waitForVolumeCreation waits for the newly created volume to become available. Volume which failed
+// to create is deleted so that it does not block the volume name for the next attempt
+func waitForVolumeCreation(ctx context.Context, logger *zap.Logger, session provider.Session, volume *provider.Volume) (*provider.Volume, error) {
+	// Provider did not report any status, nothing to wait for
+	if volume.Status == "" || volume.Status == VolumeStatusAvailable {
+		return volume, nil
+	}
+	createdVolume, err := waitForVolumeAvailable(ctx, logger, session, volume.VolumeID)
+	if err != nil && createdVolume != nil && createdVolume.Status == VolumeStatusFailed {
+		_ = deleteFailedVolume(logger, session, createdVolume)
+	}
+	return createdVolume, err
+}
+
+// deleteFailedVolume deletes the volume which could not be created by provider
+func deleteFailedVolume(logger *zap.Logger, session provider.Session, volume *provider.Volume) error {
+	logger.Warn("Deleting volume which failed to create", zap.String("VolumeID", volume.VolumeID), zap.String("Status", volume.Status))
+	if err := session.DeleteVolume(volume); err != nil {
+		logger.Error("Failed to delete volume which failed to create", zap.String("VolumeID", volume.VolumeID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// waitForSnapshotReady polls the snapshot till it is ready to use or the context expires
+func waitForSnapshotReady(ctx context.Context, logger *zap.Logger, session provider.Session, snapshot *provider.Snapshot) (*provider.Snapshot, error) {
+	ctx, cancel := contextWithDefaultTimeout(ctx)
+	defer cancel()
+	var err error
+	for !snapshot.ReadyToUse {
+		logger.Info("Waiting for snapshot to be ready", zap.String("SnapshotID", snapshot.SnapshotID))
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("snapshot '%s' is not ready to use: %v", snapshot.SnapshotID, ctx.Err())
+		case <-time.After(volumeStatusPollInterval):
+		}
+		snapshot, err = session.GetSnapshot(snapshot.SnapshotID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
 func overrideParams(logger *zap.Logger, req *csi.CreateVolumeRequest, config *config.Config, volume *provider.Volume) error {
 	var encrypt = "undef"
 	var err error
@@ -328,12 +776,7 @@ func overrideParams(logger *zap.Logger, req *csi.CreateVolumeRequest, config *co
 			}
 		case Throughput: // getting throughput value from storage class if it is provided
 			if len(value) != 0 {
-				bandwidth, errParse := strconv.ParseInt(value, 10, 32)
-				if errParse != nil {
-					err = fmt.Errorf("'<%v>' is invalid, value of '%s' should be an int32 type", value, key)
-				} else {
-					volume.Bandwidth = int32(bandwidth)
-				}
+				volume.Bandwidth, err = parseThroughput(key, value)
 			}
 		default:
 			err = fmt.Errorf("<%s> is an invalid parameter", key)
@@ -353,6 +796,41 @@ func overrideParams(logger *zap.Logger, req *csi.CreateVolumeRequest, config *co
 	return nil
 }
 
+// getVolumeMismatches returns the attributes of the existing volume which differ from the requested ones. The
+// attributes which provider does not return for the existing volume, and those left to VPC to choose e.g the
+// IOPS of tiered profiles, are not compared. Source snapshot of the clone is internal hence not compared either.
+func getVolumeMismatches(requested provider.Volume, existing provider.Volume, isClone bool) []string {
+	var mismatches []string
+	mismatch := func(attribute string, requestedValue, existingValue interface{}) {
+		mismatches = append(mismatches, fmt.Sprintf("%s(requested '%v', existing '%v')", attribute, requestedValue, existingValue))
+	}
+	if requested.Capacity != nil && existing.Capacity != nil && *requested.Capacity != *existing.Capacity {
+		mismatch("capacity", fmt.Sprintf("%dGiB", *requested.Capacity), fmt.Sprintf("%dGiB", *existing.Capacity))
+	}
+	if requested.Profile != nil && existing.Profile != nil && requested.Profile.Name != existing.Profile.Name {
+		mismatch(Profile, requested.Profile.Name, existing.Profile.Name)
+	}
+	if len(requested.Az) != 0 && len(existing.Az) != 0 && requested.Az != existing.Az {
+		mismatch(Zone, requested.Az, existing.Az)
+	}
+	if requested.Iops != nil && len(*requested.Iops) != 0 && existing.Iops != nil && *requested.Iops != *existing.Iops {
+		mismatch(IOPS, *requested.Iops, *existing.Iops)
+	}
+	if requested.Bandwidth != 0 && requested.Bandwidth != existing.Bandwidth {
+		mismatch(Throughput, requested.Bandwidth, existing.Bandwidth)
+	}
+	if !isClone {
+		snapshotID := requested.SnapshotID
+		if len(requested.SnapshotCRN) != 0 {
+			snapshotID, _ = getSnapshotAndAccountIDsFromCRN(requested.SnapshotCRN)
+		}
+		if snapshotID != existing.SnapshotID {
+			mismatch("source snapshot", snapshotID, existing.SnapshotID)
+		}
+	}
+	return mismatches
+}
+
 // checkIfVolumeExists ...
 func checkIfVolumeExists(session provider.Session, vol provider.Volume, ctxLogger *zap.Logger) (*provider.Volume, error) {
 	// Check if Requested Volume exists
@@ -389,18 +867,16 @@ func checkIfVolumeExists(session provider.Session, vol provider.Volume, ctxLogge
 	return existingVol, err
 }
 
-// createCSIVolumeResponse ...
-func createCSIVolumeResponse(vol provider.Volume, capBytes int64, zones []string, clusterID string, region string) *csi.CreateVolumeResponse {
-	var src *csi.VolumeContentSource
-	if vol.SnapshotID != "" {
-		src = &csi.VolumeContentSource{
-			Type: &csi.VolumeContentSource_Snapshot{
-				Snapshot: &csi.VolumeContentSource_SnapshotSource{
-					SnapshotId: vol.SnapshotID,
-				},
-			},
-		}
+// addVolumeContextParameters adds the parameters of the storage class which the node needs to the volume context
+func addVolumeContextParameters(response *csi.CreateVolumeResponse, parameters map[string]string) *csi.CreateVolumeResponse {
+	if value, ok := parameters[FormatRestoredVolume]; ok {
+		response.Volume.VolumeContext[FormatRestoredVolume] = value
 	}
+	return response
+}
+
+// createCSIVolumeResponse ...
+func createCSIVolumeResponse(vol provider.Volume, capBytes int64, zones []string, clusterID string, region string, src *csi.VolumeContentSource) *csi.CreateVolumeResponse {
 	labels := map[string]string{}
 
 	// Update labels for PV objects
@@ -477,6 +953,17 @@ func createCSISnapshotResponse(snapshot provider.Snapshot) *csi.CreateSnapshotRe
 	}
 }
 
+// getAttachmentDeviceID returns the device ID of the attachment from its device path. Provider does not return
+// the device ID but the virtio link of the device, which is named by the serial i.e the device ID truncated to
+// 20 chars. It is empty if the device path is not a virtio link.
+func getAttachmentDeviceID(devicePath string) string {
+	deviceID, found := strings.CutPrefix(devicePath, models.GTypeG2DevicePrefix)
+	if !found {
+		return ""
+	}
+	return deviceID
+}
+
 func createControllerPublishVolumeResponse(volumeAttachmentResponse provider.VolumeAttachmentResponse, extraPublishInfo map[string]string) *csi.ControllerPublishVolumeResponse {
 	publishContext := map[string]string{
 		PublishInfoVolumeID:   volumeAttachmentResponse.VolumeID,
@@ -484,6 +971,9 @@ func createControllerPublishVolumeResponse(volumeAttachmentResponse provider.Vol
 		PublishInfoStatus:     volumeAttachmentResponse.Status,
 		PublishInfoDevicePath: volumeAttachmentResponse.VPCVolumeAttachment.DevicePath,
 	}
+	if deviceID := getAttachmentDeviceID(volumeAttachmentResponse.VPCVolumeAttachment.DevicePath); len(deviceID) != 0 {
+		publishContext[PublishInfoDeviceID] = deviceID
+	}
 	// append extraPublishInfo
 	for k, v := range extraPublishInfo {
 		publishContext[k] = v
@@ -493,45 +983,250 @@ func createControllerPublishVolumeResponse(volumeAttachmentResponse provider.Vol
 	}
 }
 
-func pickTargetTopologyParams(top *csi.TopologyRequirement) (map[string]string, error) {
-	prefTopologyParams, err := getPrefedTopologyParams(top.GetPreferred())
-	if err != nil {
-		return nil, fmt.Errorf("could not get zones from preferred topology: %v", err)
+// createControllerGetVolumeResponse creates the get volume response with the condition of the volume. The published
+// nodes are not reported as the provider does not return the attachments of the volume
+func createControllerGetVolumeResponse(vol provider.Volume) *csi.ControllerGetVolumeResponse {
+	var capacityBytes int64
+	if vol.Capacity != nil {
+		capacityBytes = int64(*vol.Capacity) * utils.GiB
+	}
+	return &csi.ControllerGetVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      vol.VolumeID,
+			CapacityBytes: capacityBytes,
+		},
+		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+			VolumeCondition: getVolumeCondition(vol),
+		},
 	}
+}
 
-	return prefTopologyParams, nil
+// createListVolumesResponseEntry creates the list volumes entry along with the condition of the volume, so
+// that health monitor can reconcile it with the cluster. The published nodes are not reported as the provider
+// does not return the attachments of the volume.
+func createListVolumesResponseEntry(vol provider.Volume, region string) *csi.ListVolumesResponse_Entry {
+	var capacityBytes int64
+	if vol.Capacity != nil {
+		capacityBytes = int64(*vol.Capacity) * utils.GiB
+	}
+	// RIaaS does not provide Region details
+	if vol.Region != "" {
+		region = vol.Region
+	}
+
+	volume := &csi.Volume{
+		VolumeId:      vol.VolumeID,
+		CapacityBytes: capacityBytes,
+	}
+	if vol.Az != "" {
+		volume.AccessibleTopology = []*csi.Topology{
+			{
+				Segments: map[string]string{
+					utils.NodeRegionLabel: region,
+					utils.NodeZoneLabel:   vol.Az,
+				},
+			},
+		}
+	}
+
+	return &csi.ListVolumesResponse_Entry{
+		Volume: volume,
+		Status: &csi.ListVolumesResponse_VolumeStatus{
+			VolumeCondition: getVolumeCondition(vol),
+		},
+	}
+}
+
+// getVolumeCondition maps the VPC volume status to the CSI volume condition, the volume is abnormal only if
+// its status is failed, unusable or pending_deletion. The health state of the volume is not considered.
+func getVolumeCondition(vol provider.Volume) *csi.VolumeCondition {
+	switch vol.Status {
+	case VolumeStatusAvailable:
+		return &csi.VolumeCondition{Abnormal: false, Message: "volume is available"}
+	case VolumeStatusPending, VolumeStatusUpdating:
+		return &csi.VolumeCondition{Abnormal: false, Message: fmt.Sprintf("volume is in '%s' state", vol.Status)}
+	case "":
+		// Provider did not report any status, nothing to complain about
+		return &csi.VolumeCondition{Abnormal: false, Message: "volume status is unknown"}
+	default: // failed, unusable, pending_deletion and any future unhealthy state
+		return &csi.VolumeCondition{Abnormal: true, Message: fmt.Sprintf("volume is in '%s' state", vol.Status)}
+	}
 }
 
-func getPrefedTopologyParams(topList []*csi.Topology) (map[string]string, error) {
-	for _, top := range topList {
-		segment := top.GetSegments()
-		if segment != nil {
-			return segment, nil
+// getZoneLimits returns the max number of volumes and max capacity(in GiB) per zone configured for the account.
+// VPC does not expose the account quota, hence these are read from MAX_VOLUMES_PER_ZONE and MAX_CAPACITY_PER_ZONE_GB,
+// zero means no limit is configured
+func getZoneLimits(ctxLogger *zap.Logger) (int64, int64) {
+	getLimit := func(name string) int64 {
+		value, ok := os.LookupEnv(name)
+		if !ok || len(value) == 0 {
+			return 0
 		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 0 {
+			ctxLogger.Warn("Invalid value for zone limit, ignoring it", zap.String("Name", name), zap.String("Value", value))
+			return 0
+		}
+		return limit
 	}
-	return nil, fmt.Errorf("preferred topologies specified but no segments")
+	return getLimit("MAX_VOLUMES_PER_ZONE"), getLimit("MAX_CAPACITY_PER_ZONE_GB")
 }
 
-/*
-1.) IF user does not given the value DEFAULT_SNAPSHOT_CREATE_DELAY mins
-2.) IF user has given more than MAX_SNAPSHOT_CREATE_DELAY default is MAX_SNAPSHOT_CREATE_DELAY
-3.) In case of any invalid value DEFAULT_SNAPSHOT_CREATE_DELAY mins
-*/
-func getMaxDelaySnapshotCreate(ctxLogger *zap.Logger) int {
-	userDelayEnv := os.Getenv("CUSTOM_SNAPSHOT_CREATE_DELAY")
-	if userDelayEnv == "" {
-		return DEFAULT_SNAPSHOT_CREATE_DELAY
+// getAllowUntaggedVolumes returns whether the volumes not tagged with any cluster ID are taken as owned by the
+// cluster, read from ALLOW_UNTAGGED_VOLUMES. They are not if it is not set or is invalid.
+func getAllowUntaggedVolumes(logger *zap.Logger) bool {
+	value, ok := os.LookupEnv("ALLOW_UNTAGGED_VOLUMES")
+	if !ok || len(value) == 0 {
+		return false
+	}
+	if value != TrueStr && value != FalseStr {
+		logger.Warn("Invalid value for ALLOW_UNTAGGED_VOLUMES, untagged volumes are not allowed", zap.String("Value", value))
+		return false
+	}
+	return value == TrueStr
+}
+
+// getMaxConcurrentAttachPerNode returns the number of attach and detach operations run at the same time for a node,
+// read from MAX_CONCURRENT_ATTACH_PER_NODE. MaxConcurrentAttachPerNode is used if it is not set or is invalid.
+func getMaxConcurrentAttachPerNode(logger *zap.Logger) int {
+	value, ok := os.LookupEnv("MAX_CONCURRENT_ATTACH_PER_NODE")
+	if !ok || len(value) == 0 {
+		return MaxConcurrentAttachPerNode
+	}
+	maxConcurrent, err := strconv.Atoi(value)
+	if err != nil || maxConcurrent < 1 {
+		logger.Warn("Invalid value for MAX_CONCURRENT_ATTACH_PER_NODE. Setting the default value:", zap.Int("MaxConcurrentAttachPerNode", MaxConcurrentAttachPerNode))
+		return MaxConcurrentAttachPerNode
+	}
+	return maxConcurrent
+}
+
+// getZoneUsage returns the number of volumes and the total capacity(in GiB) provisioned in the zone
+func getZoneUsage(session provider.Session, zone string) (int64, int64, error) {
+	var volumeCount, capacity int64
+	tags := map[string]string{"zone.name": zone}
+	start := ""
+	for {
+		volumeList, err := session.ListVolumes(ListVolumesMaxLimit, start, tags)
+		if err != nil {
+			return 0, 0, err
+		}
+		if volumeList == nil {
+			break
+		}
+		for _, vol := range volumeList.Volumes {
+			volumeCount++
+			if vol.Capacity != nil {
+				capacity += int64(*vol.Capacity)
+			}
+		}
+		if len(volumeList.Next) == 0 {
+			break
+		}
+		start = volumeList.Next
+	}
+	return volumeCount, capacity, nil
+}
+
+// getAvailableCapacity returns the available capacity and the maximum volume size in bytes based on the zone limits and usage.
+// Without a capacity limit, the available capacity is reported as the maximum volume size of the profile
+func getAvailableCapacity(maxVolumeSize, volumeCountLimit, capacityLimit, volumeCount, capacityUsed int64) (int64, int64) {
+	if volumeCountLimit > 0 && volumeCount >= volumeCountLimit {
+		return 0, 0
 	}
+	if capacityLimit <= 0 {
+		return maxVolumeSize, maxVolumeSize
+	}
+	available := (capacityLimit - capacityUsed) * utils.GiB
+	if available < 0 {
+		available = 0
+	}
+	if available < maxVolumeSize {
+		return available, available
+	}
+	return available, maxVolumeSize
+}
 
-	customSnapshotCreateDelay, err := strconv.Atoi(userDelayEnv)
+// pickTargetTopologyParams selects the zone for a new volume. The candidate zones are the requisite
+// zones, or the storage class zones allowlist if no requisite is given, which belong to the driver
+// region. The first preferred zone among the candidates is picked, without any preference the
+// zone is picked from the hash of the volume name so that a retried request gets the same zone
+func pickTargetTopologyParams(top *csi.TopologyRequirement, allowedZones []string, region string, volumeName string) (map[string]string, error) {
+	candidates, err := getCandidateZones(top.GetRequisite(), allowedZones, region)
 	if err != nil {
-		ctxLogger.Warn("Error while processing CUSTOM_SNAPSHOT_CREATE_DELAY value.Expecting integer value in seconds", zap.Any("CUSTOM_SNAPSHOT_CREATE_DELAY", customSnapshotCreateDelay), zap.Any("Considered value", DEFAULT_SNAPSHOT_CREATE_DELAY), zap.Error(err))
-		return DEFAULT_SNAPSHOT_CREATE_DELAY // min 300 seconds default
+		return nil, err
+	}
+
+	for _, preferred := range top.GetPreferred() {
+		segments := preferred.GetSegments()
+		zone := segments[utils.NodeZoneLabel]
+		if len(zone) == 0 || !isTopologyInRegion(segments, region) {
+			continue
+		}
+		// Without requisite and allowlist any preferred zone of the region is fine
+		if len(candidates) == 0 || slices.Contains(candidates, zone) {
+			return getZoneTopologySegments(zone, region), nil
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no zone of region '%s' found in the topology requirement", region)
+	}
+	return getZoneTopologySegments(pickZone(candidates, volumeName), region), nil
+}
+
+// getCandidateZones returns the requisite zones of the driver region which are in the allowlist,
+// the allowlist itself is returned if there is no requisite topology
+func getCandidateZones(requisite []*csi.Topology, allowedZones []string, region string) ([]string, error) {
+	if len(requisite) == 0 {
+		return allowedZones, nil
 	}
-	if customSnapshotCreateDelay > MAX_SNAPSHOT_CREATE_DELAY {
-		ctxLogger.Warn("CUSTOM_SNAPSHOT_CREATE_DELAY value cannot exceed the limits", zap.Any("CUSTOM_SNAPSHOT_CREATE_DELAY", customSnapshotCreateDelay), zap.Any("Limit value", MAX_SNAPSHOT_CREATE_DELAY))
-		return MAX_SNAPSHOT_CREATE_DELAY // max 900 seconds
+
+	var zones []string
+	for _, top := range requisite {
+		segments := top.GetSegments()
+		zone := segments[utils.NodeZoneLabel]
+		if len(zone) == 0 || !isTopologyInRegion(segments, region) || slices.Contains(zones, zone) {
+			continue
+		}
+		if len(allowedZones) != 0 && !slices.Contains(allowedZones, zone) {
+			continue
+		}
+		zones = append(zones, zone)
 	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("none of the requisite topologies %v has a zone of region '%s' in the allowed zones %v", requisite, region, allowedZones)
+	}
+	return zones, nil
+}
+
+// isZoneInRegion checks the zone name is of the driver region, VPC zone names are <region>-<number>
+func isZoneInRegion(zone string, region string) bool {
+	return len(region) == 0 || strings.HasPrefix(zone, region+"-")
+}
+
+// isTopologyInRegion checks the region label of the topology segments against the driver region
+func isTopologyInRegion(segments map[string]string, region string) bool {
+	topologyRegion, ok := segments[utils.NodeRegionLabel]
+	return !ok || len(region) == 0 || topologyRegion == region
+}
+
+func getZoneTopologySegments(zone string, region string) map[string]string {
+	segments := map[string]string{utils.NodeZoneLabel: zone}
+	if len(region) != 0 {
+		segments[utils.NodeRegionLabel] = region
+	}
+	return segments
+}
+
+// pickZone picks the zone of the volume which has no zone preference from the hash of its name, which
+// spreads the volumes across the candidate zones. Candidates are sorted so that the order of the
+// topologies in the request does not matter
+func pickZone(candidates []string, volumeName string) string {
+	zones := slices.Clone(candidates)
+	slices.Sort(zones)
 
-	return customSnapshotCreateDelay
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(volumeName))
+	return zones[hash.Sum32()%uint32(len(zones))]
 }
@@ -19,6 +19,7 @@ package ibmcsidriver
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/IBM/ibm-csi-common/pkg/utils"
@@ -173,6 +174,7 @@ func TestGetVolumeParameters(t *testing.T) {
 	volumeName := "volName"
 	volumeSize := 11
 	noIops := ""
+	templatedName := "db-data"
 	testCases := []struct {
 		testCaseName   string
 		request        *csi.CreateVolumeRequest
@@ -184,8 +186,8 @@ func TestGetVolumeParameters(t *testing.T) {
 			testCaseName: "Valid create volume request-success",
 			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064, LimitBytes: utils.MinimumVolumeSizeInBytes + utils.MinimumVolumeSizeInBytes},
 				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
-				Parameters: map[string]string{Profile: "general-purpose",
-					Zone:          "testzone",
+				Parameters: map[string]string{Profile: SDPProfile,
+					Zone:          "us-south-test-1",
 					Region:        "us-south-test",
 					Tag:           "test-tag",
 					ResourceGroup: "myresourcegroups",
@@ -201,13 +203,13 @@ func TestGetVolumeParameters(t *testing.T) {
 				Capacity: &volumeSize,
 				VPCVolume: provider.VPCVolume{
 					Tags:          []string{createdByIBM},
-					Profile:       &provider.Profile{Name: "general-purpose"},
+					Profile:       &provider.Profile{Name: SDPProfile},
 					ResourceGroup: &provider.ResourceGroup{ID: "myresourcegroups"},
 					Bandwidth:     1000,
 				},
 				Region: "us-south-test",
 				Iops:   &noIops,
-				Az:     "testzone",
+				Az:     "us-south-test-1",
 			},
 			expectedStatus: true,
 			expectedError:  nil,
@@ -229,6 +231,69 @@ func TestGetVolumeParameters(t *testing.T) {
 			expectedStatus: true,
 			expectedError:  fmt.Errorf("%s:<%v> exceeds %d chars", Zone, exceededZoneName, ZoneNameMaxLen),
 		},
+		{
+			testCaseName: "Zone not in the allowed zones",
+			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+				Parameters: map[string]string{Profile: "general-purpose",
+					Zone:  "myregion-3",
+					Zones: "myregion-1, myregion-2",
+				},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("%s:<%v> is not one of the allowed %s %v", Zone, "myregion-3", Zones, []string{"myregion-1", "myregion-2"}),
+		},
+		{
+			testCaseName: "Zone not of the region",
+			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+				Parameters: map[string]string{Profile: "general-purpose",
+					Zone: "otherregion-1",
+				},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("%s:<%v> is not a zone of the region '%s'", Zone, "otherregion-1", "myregion"),
+		},
+		{
+			testCaseName: "Zone picked from requisite topology in the allowed zones",
+			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+				Parameters: map[string]string{Profile: "general-purpose",
+					Zones: "myzone-2",
+				},
+				AccessibilityRequirements: &csi.TopologyRequirement{
+					Requisite: []*csi.Topology{
+						{Segments: map[string]string{utils.NodeRegionLabel: "myregion", utils.NodeZoneLabel: "myzone-1"}},
+						{Segments: map[string]string{utils.NodeRegionLabel: "myregion", utils.NodeZoneLabel: "myzone-2"}},
+					},
+					Preferred: []*csi.Topology{
+						{Segments: map[string]string{utils.NodeRegionLabel: "myregion", utils.NodeZoneLabel: "myzone-1"}},
+						{Segments: map[string]string{utils.NodeRegionLabel: "myregion", utils.NodeZoneLabel: "myzone-2"}},
+					},
+				},
+			},
+			expectedVolume: &provider.Volume{Name: &volumeName,
+				Capacity: &volumeSize,
+				VPCVolume: provider.VPCVolume{
+					Profile: &provider.Profile{Name: "general-purpose"},
+				},
+				Az: "myzone-2",
+			},
+			expectedStatus: true,
+			expectedError:  nil,
+		},
+		{
+			testCaseName: "Max length exceeded for zones allowlist",
+			request: &csi.CreateVolumeRequest{Parameters: map[string]string{
+				Zones: "myzone-1," + exceededZoneName,
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("%s:<%v> exceeds %d chars", Zones, exceededZoneName, ZoneNameMaxLen),
+		},
 		{
 			testCaseName: "Max length exceeded for region name",
 			request: &csi.CreateVolumeRequest{Parameters: map[string]string{
@@ -259,6 +324,104 @@ func TestGetVolumeParameters(t *testing.T) {
 			expectedStatus: true,
 			expectedError:  fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", "noTrueNoFalse", Encrypted),
 		},
+		{
+			testCaseName: "IOPS out of the range of the volume size",
+			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+				Parameters: map[string]string{Profile: CustomProfile,
+					Zone: "testzone",
+					IOPS: "2000",
+				},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("%s:<%d> is out of the supported range %d-%d%s of '%s' profile", IOPS, 2000, 100, 1000, " for 11GiB volume", CustomProfile),
+		},
+		{
+			testCaseName: "Wrong shared volume value",
+			request: &csi.CreateVolumeRequest{Parameters: map[string]string{
+				SharedVolume: "yes",
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", "yes", SharedVolume),
+		},
+		{
+			testCaseName: "Wrong snapshot on delete value",
+			request: &csi.CreateVolumeRequest{Parameters: map[string]string{
+				SnapshotOnDelete: "yes",
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", "yes", SnapshotOnDelete),
+		},
+		{
+			testCaseName: "Wrong format restored volume value",
+			request: &csi.CreateVolumeRequest{Parameters: map[string]string{
+				FormatRestoredVolume: "yes",
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("'<%v>' is invalid, value of '%s' should be [true|false]", "yes", FormatRestoredVolume),
+		},
+		{
+			testCaseName: "Volume name and tags from templates",
+			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+				Parameters: map[string]string{Profile: "general-purpose",
+					Zone:            "us-south-test-1",
+					Region:          "us-south-test",
+					NameTemplate:    "${pvc.namespace}-${pvc.name}",
+					TagTemplate:     "namespace:${pvc.namespace}, pv:${pv.name}",
+					PVCNameKey:      "data",
+					PVCNamespaceKey: "db",
+					PVNameKey:       "pvc-1234",
+				},
+			},
+			expectedVolume: &provider.Volume{Name: &templatedName,
+				Capacity: &volumeSize,
+				Region:   "us-south-test",
+				Az:       "us-south-test-1",
+			},
+			expectedStatus: true,
+			expectedError:  nil,
+		},
+		{
+			testCaseName: "Unknown variable in name template",
+			request: &csi.CreateVolumeRequest{Name: volumeName, Parameters: map[string]string{
+				NameTemplate: "${pvc.uid}",
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError: fmt.Errorf("%s:<%v> has unknown variable '%s'. Supported variables are: %v", NameTemplate, "${pvc.uid}", "${pvc.uid}",
+				[]string{"${pvc.name}", "${pvc.namespace}", "${pv.name}"}),
+		},
+		{
+			testCaseName: "Name template without provisioner metadata",
+			request: &csi.CreateVolumeRequest{Name: volumeName, Parameters: map[string]string{
+				NameTemplate: "${pvc.name}",
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError:  fmt.Errorf("%s:<%v> needs '%s' which is passed only if csi-provisioner runs with --extra-create-metadata", NameTemplate, "${pvc.name}", PVCNameKey),
+		},
+		{
+			testCaseName: "Name template resolving to an invalid volume name",
+			request: &csi.CreateVolumeRequest{Name: volumeName, Parameters: map[string]string{
+				NameTemplate: "${pvc.name}-",
+				PVCNameKey:   "data",
+			},
+			},
+			expectedVolume: &provider.Volume{},
+			expectedStatus: true,
+			expectedError: fmt.Errorf("%s:<%v> resolves to '%s' which is not a valid volume name, it should be at most %d chars of lowercase letters, digits and hyphens starting with a letter",
+				NameTemplate, "${pvc.name}-", "data-", VolumeNameMaxLen),
+		},
 		{
 			testCaseName: "Max length exceeded for encryption key",
 			request: &csi.CreateVolumeRequest{Parameters: map[string]string{
@@ -374,16 +537,155 @@ func TestGetVolumeParameters(t *testing.T) {
 
 	for _, testcase := range testCases {
 		t.Run(testcase.testCaseName, func(t *testing.T) {
-			actualVolume, err := getVolumeParameters(logger, testcase.request, testConfig)
+			actualVolume, err := getVolumeParameters(logger, testcase.request, testConfig, "myregion")
 			if testcase.expectedError != nil {
 				assert.Equal(t, err, testcase.expectedError)
 			} else {
+				assert.Nil(t, err)
 				assert.Equal(t, testcase.expectedStatus, isVolumeSame(testcase.expectedVolume, actualVolume))
 			}
 		})
 	}
 }
 
+func TestGetVolumeParametersRejectsThroughput(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	request := &csi.CreateVolumeRequest{Name: "volName", CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+		VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+		Parameters:         map[string]string{Profile: GeneralPurposeProfile, Zone: "myregion-1", Throughput: "1000"},
+	}
+	_, err := getVolumeParameters(logger, request, &config.Config{VPC: &config.VPCProviderConfig{}}, "myregion")
+	assert.Equal(t, fmt.Errorf("'%s' can be specified only for '%s' profile", Throughput, SDPProfile), err)
+
+	// Throughput of sdp volumes is validated against the range of the profile
+	request.Parameters[Profile] = SDPProfile
+	volume, err := getVolumeParameters(logger, request, &config.Config{VPC: &config.VPCProviderConfig{}}, "myregion")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1000), volume.Bandwidth)
+}
+
+func TestGetVolumeParametersTagsRequestName(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	request := &csi.CreateVolumeRequest{Name: "pvc-1234", CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064},
+		VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+		Parameters: map[string]string{Profile: GeneralPurposeProfile, Zone: "myregion-1",
+			NameTemplate: "${pvc.namespace}-${pvc.name}", PVCNameKey: "data", PVCNamespaceKey: "db"},
+	}
+	volume, err := getVolumeParameters(logger, request, &config.Config{VPC: &config.VPCProviderConfig{}}, "myregion")
+	assert.Nil(t, err)
+	assert.Equal(t, "db-data", *volume.Name)
+	assert.Contains(t, volume.Tags, RequestNameLabel+":pvc-1234")
+	assert.Equal(t, "pvc-1234", getVolumeRequestName(*volume))
+
+	// Volumes named by the request are not tagged
+	delete(request.Parameters, NameTemplate)
+	volume, err = getVolumeParameters(logger, request, &config.Config{VPC: &config.VPCProviderConfig{}}, "myregion")
+	assert.Nil(t, err)
+	assert.Empty(t, getVolumeRequestName(*volume))
+}
+
+func TestGetSnapshotParameters(t *testing.T) {
+	snapshotName := "snap-name"
+	testCases := []struct {
+		testCaseName   string
+		request        *csi.CreateSnapshotRequest
+		expectedOutput provider.SnapshotParameters
+		expectedError  error
+	}{
+		{
+			testCaseName: "Snapshot without parameters",
+			request:      &csi.CreateSnapshotRequest{Name: snapshotName, SourceVolumeId: "volID"},
+			expectedOutput: provider.SnapshotParameters{Name: snapshotName,
+				SnapshotTags: provider.SnapshotTags{"name": snapshotName},
+			},
+			expectedError: nil,
+		},
+		{
+			testCaseName: "Snapshot metadata is ignored",
+			request: &csi.CreateSnapshotRequest{Name: snapshotName, SourceVolumeId: "volID",
+				Parameters: map[string]string{
+					VolumeSnapshotNameKey:        "my-snapshot",
+					VolumeSnapshotNamespaceKey:   "default",
+					VolumeSnapshotContentNameKey: "snapcontent-1",
+				},
+			},
+			expectedOutput: provider.SnapshotParameters{Name: snapshotName,
+				SnapshotTags: provider.SnapshotTags{"name": snapshotName},
+			},
+			expectedError: nil,
+		},
+		{
+			testCaseName:  "Unsupported tags parameter",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Parameters: map[string]string{Tag: "env:test"}},
+			expectedError: fmt.Errorf("<%s> is not supported for snapshots", Tag),
+		},
+		{
+			testCaseName:  "Unsupported secret tags",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Secrets: map[string]string{Tag: "owner:storage"}},
+			expectedError: fmt.Errorf("<%s> is not supported for snapshots", Tag),
+		},
+		{
+			testCaseName:  "Unsupported resource group parameter",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Parameters: map[string]string{ResourceGroup: "myresourcegroup"}},
+			expectedError: fmt.Errorf("<%s> is not supported for snapshots", ResourceGroup),
+		},
+		{
+			testCaseName:  "Unsupported copy to regions parameter",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Parameters: map[string]string{CopyToRegions: "eu-de"}},
+			expectedError: fmt.Errorf("<%s> is not supported, snapshots can not be copied to other regions by the driver", CopyToRegions),
+		},
+		{
+			testCaseName:  "Unsupported fast restore zones parameter",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Parameters: map[string]string{FastRestoreZones: "us-south-1"}},
+			expectedError: fmt.Errorf("<%s> is not supported, fast restore can not be enabled on snapshots by the driver", FastRestoreZones),
+		},
+		{
+			testCaseName:  "Invalid parameter",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Parameters: map[string]string{Profile: "general-purpose"}},
+			expectedError: fmt.Errorf("<%s> is an invalid parameter", Profile),
+		},
+		{
+			testCaseName:  "Invalid secret parameter",
+			request:       &csi.CreateSnapshotRequest{Name: snapshotName, Secrets: map[string]string{Zone: "testzone"}},
+			expectedError: fmt.Errorf("<%s> is an invalid parameter", Zone),
+		},
+	}
+
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			actualOutput, err := getSnapshotParameters(logger, testcase.request)
+			if testcase.expectedError != nil {
+				assert.Equal(t, testcase.expectedError, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, testcase.expectedOutput, actualOutput)
+			}
+		})
+	}
+}
+
+func TestResolveVolumeTags(t *testing.T) {
+	metadata := map[string]string{PVCNameKey: "data", PVCNamespaceKey: "db"}
+
+	tags, err := resolveVolumeTags("team:storage, pvc:${pvc.namespace}/${pvc.name},", metadata)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"team:storage", "pvc:db/data"}, tags)
+
+	_, err = resolveVolumeTags("pv:${pv.name}", metadata)
+	assert.Equal(t, fmt.Errorf("%s:<%v> needs '%s' which is passed only if csi-provisioner runs with --extra-create-metadata", TagTemplate, "pv:${pv.name}", PVNameKey), err)
+
+	longName := strings.Repeat("a", TagMaxLen)
+	_, err = resolveVolumeTags("pvc:${pvc.name}", map[string]string{PVCNameKey: longName})
+	assert.Equal(t, fmt.Errorf("%s:<%v> exceeds %d chars", TagTemplate, "pvc:"+longName, TagMaxLen), err)
+}
+
 func TestOverrideParams(t *testing.T) {
 	volumeName := "volName"
 	volumeSize := 11 // in Gib which is equal to 11811160064 byte
@@ -401,7 +703,7 @@ func TestOverrideParams(t *testing.T) {
 			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064, LimitBytes: utils.MinimumVolumeSizeInBytes + utils.MinimumVolumeSizeInBytes},
 				VolumeCapabilities: []*csi.VolumeCapability{{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
 				Parameters: map[string]string{Profile: "general-purpose",
-					Zone:          "testzone",
+					Zone:          "us-south-test-1",
 					Region:        "us-south-test",
 					Tag:           "test",
 					ResourceGroup: "myresourcegroups",
@@ -492,7 +794,7 @@ func TestOverrideParams(t *testing.T) {
 			testCaseName: "Valid IOPS for custom class",
 			request: &csi.CreateVolumeRequest{Name: volumeName, CapacityRange: &csi.CapacityRange{RequiredBytes: 11811160064, LimitBytes: utils.MinimumVolumeSizeInBytes + utils.MinimumVolumeSizeInBytes},
 				Parameters: map[string]string{Profile: "custom",
-					Zone:          "testzone",
+					Zone:          "us-south-test-1",
 					Region:        "us-south-test",
 					Tag:           "test",
 					ResourceGroup: "myresourcegroups",
@@ -669,7 +971,7 @@ func TestCreateCSIVolumeResponse(t *testing.T) {
 
 	for _, testcase := range testCases {
 		t.Run(testcase.testCaseName, func(t *testing.T) {
-			actualCSIVolume := createCSIVolumeResponse(testcase.requestVol, testcase.requestCap, testcase.requestZones, testcase.clusterID, icDriver.region)
+			actualCSIVolume := createCSIVolumeResponse(testcase.requestVol, testcase.requestCap, testcase.requestZones, testcase.clusterID, icDriver.region, nil)
 			assert.Equal(t, testcase.expectedStatus, isCSIResponseSame(testcase.expectedVolume, actualCSIVolume))
 		})
 	}
@@ -687,6 +989,12 @@ func isControllerPublishVolume(expected *csi.ControllerPublishVolumeResponse, ac
 	return expected.PublishContext[PublishInfoVolumeID] == actual.PublishContext[PublishInfoVolumeID]
 }
 
+func TestAddVolumeContextParameters(t *testing.T) {
+	response := &csi.CreateVolumeResponse{Volume: &csi.Volume{VolumeContext: map[string]string{VolumeIDLabel: "volumeID"}}}
+	response = addVolumeContextParameters(response, map[string]string{Profile: GeneralPurposeProfile, FormatRestoredVolume: TrueStr})
+	assert.Equal(t, map[string]string{VolumeIDLabel: "volumeID", FormatRestoredVolume: TrueStr}, response.Volume.VolumeContext)
+}
+
 func TestCreateControllerPublishVolumeResponse(t *testing.T) {
 	testCases := []struct {
 		testCaseName              string
@@ -716,94 +1024,334 @@ func TestCreateControllerPublishVolumeResponse(t *testing.T) {
 			},
 			expectedStatus: true,
 		},
+		{
+			testCaseName: "Device ID from the virtio device path",
+			requestVolAttResponse: provider.VolumeAttachmentResponse{
+				Status: "attached",
+				VolumeAttachmentRequest: provider.VolumeAttachmentRequest{
+					VolumeID:            "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f",
+					InstanceID:          "instanceID",
+					VPCVolumeAttachment: &provider.VolumeAttachment{DevicePath: "/dev/disk/by-id/virtio-0717-2b8c6d4e-3f1a-4"},
+				},
+			},
+			extraPublishInfo: map[string]string{},
+			expectedCtlPubVolResponse: &csi.ControllerPublishVolumeResponse{
+				PublishContext: map[string]string{
+					PublishInfoVolumeID:   "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f",
+					PublishInfoNodeID:     "instanceID",
+					PublishInfoStatus:     "attached",
+					PublishInfoDevicePath: "/dev/disk/by-id/virtio-0717-2b8c6d4e-3f1a-4",
+					PublishInfoDeviceID:   "0717-2b8c6d4e-3f1a-4",
+				},
+			},
+			expectedStatus: true,
+		},
 	}
 
 	for _, testcase := range testCases {
 		t.Run(testcase.testCaseName, func(t *testing.T) {
 			actualCtlPubVol := createControllerPublishVolumeResponse(testcase.requestVolAttResponse, testcase.extraPublishInfo)
 			assert.Equal(t, testcase.expectedStatus, isControllerPublishVolume(testcase.expectedCtlPubVolResponse, actualCtlPubVol))
+			assert.Equal(t, testcase.expectedCtlPubVolResponse.PublishContext[PublishInfoDeviceID], actualCtlPubVol.PublishContext[PublishInfoDeviceID])
 		})
 	}
 }
 
 func TestPickTargetTopologyParams(t *testing.T) {
-	//pickTargetTopologyParams(top *csi.TopologyRequirement) (map[string]string, error)
+	region := "us-south-test"
+	zone1 := map[string]string{utils.NodeRegionLabel: region, utils.NodeZoneLabel: "testzone-1"}
+	zone2 := map[string]string{utils.NodeRegionLabel: region, utils.NodeZoneLabel: "testzone-2"}
+	otherRegionZone := map[string]string{utils.NodeRegionLabel: "eu-de-test", utils.NodeZoneLabel: "testzone-3"}
 	testCases := []struct {
 		testCaseName    string
 		requestTopology *csi.TopologyRequirement
+		allowedZones    []string
 		expectedOutput  map[string]string
 		expectedError   error
 	}{
 		{
-			testCaseName: "Valid pick target for topology",
-			requestTopology: &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: map[string]string{
-				utils.NodeRegionLabel: "us-south-test",
-				utils.NodeZoneLabel:   "testzone",
-			},
-			},
-			},
+			testCaseName:    "Valid pick target for topology",
+			requestTopology: &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: zone1}}},
+			expectedOutput:  zone1,
+			expectedError:   nil,
+		},
+		{
+			testCaseName: "Preferred zone which is also requisite",
+			requestTopology: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: zone1}, {Segments: zone2}},
+				Preferred: []*csi.Topology{{Segments: zone2}, {Segments: zone1}},
 			},
-			expectedOutput: map[string]string{utils.NodeRegionLabel: "us-south-test",
-				utils.NodeZoneLabel: "testzone",
+			expectedOutput: zone2,
+			expectedError:  nil,
+		},
+		{
+			testCaseName: "Preferred zone not in the allowed zones",
+			requestTopology: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: zone1}, {Segments: zone2}},
+				Preferred: []*csi.Topology{{Segments: zone2}, {Segments: zone1}},
 			},
-			expectedError: nil,
+			allowedZones:   []string{"testzone-1"},
+			expectedOutput: zone1,
+			expectedError:  nil,
+		},
+		{
+			testCaseName:    "Preferred zone of other region is skipped",
+			requestTopology: &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: otherRegionZone}, {Segments: zone2}}},
+			expectedOutput:  zone2,
+			expectedError:   nil,
+		},
+		{
+			testCaseName:    "Only requisite topology",
+			requestTopology: &csi.TopologyRequirement{Requisite: []*csi.Topology{{Segments: zone2}}},
+			expectedOutput:  zone2,
+			expectedError:   nil,
+		},
+		{
+			testCaseName:    "Only allowed zones",
+			requestTopology: nil,
+			allowedZones:    []string{"testzone-1"},
+			expectedOutput:  zone1,
+			expectedError:   nil,
+		},
+		{
+			testCaseName:    "Requisite topology of other region",
+			requestTopology: &csi.TopologyRequirement{Requisite: []*csi.Topology{{Segments: otherRegionZone}}},
+			expectedOutput:  nil,
+			expectedError:   fmt.Errorf("none of the requisite topologies %v has a zone of region '%s' in the allowed zones %v", []*csi.Topology{{Segments: otherRegionZone}}, region, []string(nil)),
 		},
 		{
 			testCaseName:    "Nil pick target for topology",
 			requestTopology: &csi.TopologyRequirement{Preferred: []*csi.Topology{}},
 			expectedOutput:  nil,
-			expectedError:   fmt.Errorf("could not get zones from preferred topology: %v", fmt.Errorf("preferred topologies specified but no segments")),
+			expectedError:   fmt.Errorf("no zone of region '%s' found in the topology requirement", region),
 		},
 	}
 
 	for _, testcase := range testCases {
 		t.Run(testcase.testCaseName, func(t *testing.T) {
-			actualCtlPubVol, err := pickTargetTopologyParams(testcase.requestTopology)
+			actualCtlPubVol, err := pickTargetTopologyParams(testcase.requestTopology, testcase.allowedZones, region, "test-volume")
 			if testcase.expectedError == nil {
+				assert.Nil(t, err)
 				assert.Equal(t, testcase.expectedOutput, actualCtlPubVol)
 			} else {
-				assert.Equal(t, testcase.expectedError, err)
+				assert.Equal(t, testcase.expectedError.Error(), err.Error())
 			}
 		})
 	}
 }
 
-func TestGetPrefedTopologyParams(t *testing.T) {
+func TestPickTargetTopologyParamsByVolumeName(t *testing.T) {
+	requestTopology := &csi.TopologyRequirement{Requisite: []*csi.Topology{
+		{Segments: map[string]string{utils.NodeZoneLabel: "testzone-2"}},
+		{Segments: map[string]string{utils.NodeZoneLabel: "testzone-1"}},
+	}}
+	reversedTopology := &csi.TopologyRequirement{Requisite: []*csi.Topology{requestTopology.Requisite[1], requestTopology.Requisite[0]}}
+
+	picked := map[string]int{}
+	for i := 0; i < 20; i++ {
+		volumeName := fmt.Sprintf("pvc-%d", i)
+		zones, err := pickTargetTopologyParams(requestTopology, nil, "", volumeName)
+		assert.Nil(t, err)
+		picked[zones[utils.NodeZoneLabel]]++
+
+		// Retried request gets the same zone whatever the order of the topologies
+		retriedZones, err := pickTargetTopologyParams(reversedTopology, nil, "", volumeName)
+		assert.Nil(t, err)
+		assert.Equal(t, zones, retriedZones)
+	}
+	assert.Equal(t, 2, len(picked))
+}
+
+func TestGetVolumeCondition(t *testing.T) {
 	testCases := []struct {
-		testCaseName    string
-		requestTopology []*csi.Topology
-		expectedOutput  map[string]string
-		expectedError   error
+		testCaseName   string
+		status         string
+		expectedOutput *csi.VolumeCondition
 	}{
 		{
-			testCaseName: "Valid preferred topology params",
-			requestTopology: []*csi.Topology{{Segments: map[string]string{
-				utils.NodeRegionLabel: "us-south-test",
-				utils.NodeZoneLabel:   "testzone",
-			},
-			},
-			},
-			expectedOutput: map[string]string{utils.NodeRegionLabel: "us-south-test",
-				utils.NodeZoneLabel: "testzone",
+			testCaseName:   "Available volume",
+			status:         VolumeStatusAvailable,
+			expectedOutput: &csi.VolumeCondition{Abnormal: false, Message: "volume is available"},
+		},
+		{
+			testCaseName:   "Updating volume",
+			status:         VolumeStatusUpdating,
+			expectedOutput: &csi.VolumeCondition{Abnormal: false, Message: "volume is in 'updating' state"},
+		},
+		{
+			testCaseName:   "Unusable volume",
+			status:         VolumeStatusUnusable,
+			expectedOutput: &csi.VolumeCondition{Abnormal: true, Message: "volume is in 'unusable' state"},
+		},
+		{
+			testCaseName:   "Unknown status",
+			status:         "",
+			expectedOutput: &csi.VolumeCondition{Abnormal: false, Message: "volume status is unknown"},
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			vol := provider.Volume{VPCVolume: provider.VPCVolume{Status: testcase.status}}
+			assert.Equal(t, testcase.expectedOutput, getVolumeCondition(vol))
+		})
+	}
+}
+
+func TestCreateListVolumesResponseEntry(t *testing.T) {
+	volumeID := "volID"
+	capacity := 10
+	testCases := []struct {
+		testCaseName   string
+		requestVol     provider.Volume
+		expectedOutput *csi.ListVolumesResponse_Entry
+	}{
+		{
+			testCaseName: "Available volume",
+			requestVol: provider.Volume{VolumeID: volumeID, Capacity: &capacity, Az: "testzone",
+				VPCVolume: provider.VPCVolume{Status: VolumeStatusAvailable}},
+			expectedOutput: &csi.ListVolumesResponse_Entry{
+				Volume: &csi.Volume{
+					VolumeId:      volumeID,
+					CapacityBytes: 10 * utils.GiB,
+					AccessibleTopology: []*csi.Topology{
+						{Segments: map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "testzone"}},
+					},
+				},
+				Status: &csi.ListVolumesResponse_VolumeStatus{
+					VolumeCondition: &csi.VolumeCondition{Abnormal: false, Message: "volume is available"},
+				},
 			},
-			expectedError: nil,
 		},
 		{
-			testCaseName:    "With nil preferred topology params",
-			requestTopology: []*csi.Topology{},
-			expectedOutput:  nil,
-			expectedError:   fmt.Errorf("preferred topologies specified but no segments"),
+			testCaseName: "Failed volume without zone",
+			requestVol:   provider.Volume{VolumeID: volumeID, Capacity: &capacity, Region: "us-south-test", VPCVolume: provider.VPCVolume{Status: VolumeStatusFailed}},
+			expectedOutput: &csi.ListVolumesResponse_Entry{
+				Volume: &csi.Volume{
+					VolumeId:      volumeID,
+					CapacityBytes: 10 * utils.GiB,
+				},
+				Status: &csi.ListVolumesResponse_VolumeStatus{
+					VolumeCondition: &csi.VolumeCondition{Abnormal: true, Message: "volume is in 'failed' state"},
+				},
+			},
 		},
 	}
 
 	for _, testcase := range testCases {
 		t.Run(testcase.testCaseName, func(t *testing.T) {
-			actualCtlPubVol, err := getPrefedTopologyParams(testcase.requestTopology)
-			if testcase.expectedError == nil {
-				assert.Equal(t, testcase.expectedOutput, actualCtlPubVol)
-			} else {
-				assert.Equal(t, testcase.expectedError, err)
-			}
+			assert.Equal(t, testcase.expectedOutput, createListVolumesResponseEntry(testcase.requestVol, "testregion"))
 		})
 	}
 }
+
+func TestGetVolumeMismatches(t *testing.T) {
+	capacity := 20
+	iops := "3000"
+	requested := provider.Volume{Capacity: &capacity, Iops: &iops, Az: "us-south-1"}
+	requested.Profile = &provider.Profile{Name: CustomProfile}
+	requested.SnapshotCRN = "crn:v1:service:public:is:us-south:a/c468d8642937fecd8a0860fe0f379bf9::snapshot:snap-1"
+
+	existing := requested
+	existing.SnapshotID = "snap-1"
+	assert.Empty(t, getVolumeMismatches(requested, existing, false))
+
+	otherCapacity := 30
+	otherIops := "6000"
+	existing.Capacity = &otherCapacity
+	existing.Iops = &otherIops
+	existing.Profile = &provider.Profile{Name: SDPProfile}
+	existing.Az = "us-south-2"
+	existing.Bandwidth = 1000
+	existing.SnapshotID = ""
+	assert.Equal(t, []string{
+		"capacity(requested '20GiB', existing '30GiB')",
+		"profile(requested 'custom', existing 'sdp')",
+		"zone(requested 'us-south-1', existing 'us-south-2')",
+		"iops(requested '3000', existing '6000')",
+		"source snapshot(requested 'snap-1', existing '')",
+	}, getVolumeMismatches(requested, existing, false))
+
+	// Source snapshot of the clone is internal
+	requested.SnapshotCRN = ""
+	existing = requested
+	existing.SnapshotID = "clone-snap"
+	assert.Empty(t, getVolumeMismatches(requested, existing, true))
+	assert.NotEmpty(t, getVolumeMismatches(requested, existing, false))
+}
+
+func TestCheckVolumeRequestName(t *testing.T) {
+	requested := provider.Volume{}
+	requested.Tags = []string{RequestNameLabel + ":pvc-1"}
+	existing := provider.Volume{VolumeID: "vol-1"}
+	existing.Tags = []string{RequestNameLabel + ":pvc-1"}
+	assert.Nil(t, checkVolumeRequestName(requested, existing))
+
+	// Templated name of another request
+	existing.Tags = []string{RequestNameLabel + ":pvc-2"}
+	assert.Equal(t, fmt.Errorf("volume '%s' is created for the request '%s', not for the request '%s'", "vol-1", "pvc-2", "pvc-1"), checkVolumeRequestName(requested, existing))
+	existing.Tags = nil
+	assert.NotNil(t, checkVolumeRequestName(requested, existing))
+
+	// Volumes named by the request are not checked
+	requested.Tags = nil
+	assert.Nil(t, checkVolumeRequestName(requested, existing))
+}
+
+func TestIsVolumeOfOtherCluster(t *testing.T) {
+	volume := provider.Volume{VolumeID: "vol-1"}
+	volume.Tags = []string{"env:test", "ClusterID:Cluster-1"}
+
+	assert.False(t, isVolumeOfOtherCluster(volume, "cluster-1"))
+	assert.True(t, isVolumeOfOtherCluster(volume, "cluster-2"))
+	// Nothing is filtered if the cluster ID is not known
+	assert.False(t, isVolumeOfOtherCluster(volume, ""))
+
+	// Volume created before the tagging is listed
+	volume.Tags = []string{"env:test"}
+	assert.False(t, isVolumeOfOtherCluster(volume, "cluster-2"))
+
+	volume.Tags = []string{"ClusterID:Cluster-1", SharedVolumeTag}
+	assert.False(t, isVolumeOfOtherCluster(volume, "cluster-2"))
+}
+
+func TestCheckVolumeOwnership(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	volume := provider.Volume{VolumeID: "vol-1"}
+	volume.Tags = []string{"env:test", "ClusterID:Cluster-1"}
+
+	assert.Nil(t, checkVolumeOwnership(logger, volume, "cluster-1", false))
+	// Nothing is checked if the cluster ID is not known
+	assert.Nil(t, checkVolumeOwnership(logger, volume, "", false))
+	assert.Equal(t, fmt.Errorf("volume '%s' is owned by the clusters %v, not by the cluster '%s'. Tag it with '%s' to allow it",
+		"vol-1", []string{"cluster-1"}, "cluster-2", SharedVolumeTag), checkVolumeOwnership(logger, volume, "cluster-2", true))
+
+	volume.Tags = append(volume.Tags, SharedVolumeTag)
+	assert.Nil(t, checkVolumeOwnership(logger, volume, "cluster-2", false))
+
+	// Volume created before the tagging or in a cluster without the PV watcher is owned only if allowed
+	volume.Tags = []string{"env:test"}
+	assert.Equal(t, fmt.Errorf("volume '%s' is not tagged with the ID of any cluster. Tag it with '%s:%s' or '%s', or set ALLOW_UNTAGGED_VOLUMES to allow it",
+		"vol-1", ClusterIDLabel, "cluster-2", SharedVolumeTag), checkVolumeOwnership(logger, volume, "cluster-2", false))
+	assert.Nil(t, checkVolumeOwnership(logger, volume, "cluster-2", true))
+
+	// Tag added by the PV watcher for the static PV without the cluster ID
+	volume.Tags = []string{ClusterIDLabel + ":"}
+	assert.NotNil(t, checkVolumeOwnership(logger, volume, "cluster-2", false))
+	assert.Nil(t, checkVolumeOwnership(logger, volume, "cluster-2", true))
+}
+
+func TestGetAllowUntaggedVolumes(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	assert.False(t, getAllowUntaggedVolumes(logger))
+	t.Setenv("ALLOW_UNTAGGED_VOLUMES", TrueStr)
+	assert.True(t, getAllowUntaggedVolumes(logger))
+	t.Setenv("ALLOW_UNTAGGED_VOLUMES", FalseStr)
+	assert.False(t, getAllowUntaggedVolumes(logger))
+	t.Setenv("ALLOW_UNTAGGED_VOLUMES", "yes")
+	assert.False(t, getAllowUntaggedVolumes(logger))
+}
@@ -40,6 +40,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 var (
@@ -80,16 +81,18 @@ var (
 	stdParams = map[string]string{
 		//"type": "ext2",
 		Profile: "general-purpose",
-		Zone:    "myzone",
-		Region:  "myregion",
+		Zone:    "testregion-1",
 	}
 	stdTopology = []*csi.Topology{
 		{
-			Segments: map[string]string{utils.NodeZoneLabel: "myzone", utils.NodeRegionLabel: "myregion"},
+			Segments: map[string]string{utils.NodeZoneLabel: "testregion-1", utils.NodeRegionLabel: "testregion"},
 		},
 	}
 )
 
+// ownedTag is the cluster ID tag of the volumes owned by the cluster of the fake provider
+const ownedTag = ClusterIDLabel + ":fake-clusterID"
+
 func TestCreateVolumeArguments(t *testing.T) {
 	cap := 20
 	volName := "test-name"
@@ -114,10 +117,10 @@ func TestCreateVolumeArguments(t *testing.T) {
 			expVol: &csi.Volume{
 				CapacityBytes:      20 * 1024 * 1024 * 1024, // In byte
 				VolumeId:           "testVolumeId",
-				VolumeContext:      map[string]string{utils.NodeRegionLabel: "myregion", utils.NodeZoneLabel: "myzone", VolumeIDLabel: "testVolumeId", Tag: "", VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
+				VolumeContext:      map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "testregion-1", VolumeIDLabel: "testVolumeId", Tag: ownedTag, VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
 				AccessibleTopology: stdTopology,
 			},
-			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "myzone", Region: "myregion"},
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
 			expErrCode:        codes.OK,
 			libVolumeError:    nil,
 		},
@@ -208,11 +211,11 @@ func TestCreateVolumeArguments(t *testing.T) {
 				Parameters: map[string]string{
 					//"type": "ext2",
 					Profile: "general-purpose",
-					Zone:    "myzone",
+					Zone:    "testregion-1",
 				},
 				AccessibilityRequirements: &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: map[string]string{
-					utils.NodeRegionLabel: "myregion",
-					utils.NodeZoneLabel:   "myzone",
+					utils.NodeRegionLabel: "testregion",
+					utils.NodeZoneLabel:   "testregion-1",
 				},
 				},
 				},
@@ -221,14 +224,14 @@ func TestCreateVolumeArguments(t *testing.T) {
 			expVol: &csi.Volume{
 				CapacityBytes: 20 * 1024 * 1024 * 1024, // In byte
 				VolumeId:      "testVolumeId",
-				VolumeContext: map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "myzone", VolumeIDLabel: "testVolumeId", Tag: "", VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
+				VolumeContext: map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "testregion-1", VolumeIDLabel: "testVolumeId", Tag: ownedTag, VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
 				AccessibleTopology: []*csi.Topology{
 					{
-						Segments: map[string]string{utils.NodeZoneLabel: "myzone", utils.NodeRegionLabel: "testregion"},
+						Segments: map[string]string{utils.NodeZoneLabel: "testregion-1", utils.NodeRegionLabel: "testregion"},
 					},
 				},
 			},
-			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "myzone", Region: "myregion"},
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
 			expErrCode:        codes.OK,
 			libVolumeError:    nil,
 		},
@@ -244,8 +247,8 @@ func TestCreateVolumeArguments(t *testing.T) {
 					Profile: "general-purpose",
 				},
 				AccessibilityRequirements: &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: map[string]string{
-					utils.NodeRegionLabel: "myregion",
-					utils.NodeZoneLabel:   "myzone",
+					utils.NodeRegionLabel: "testregion",
+					utils.NodeZoneLabel:   "testregion-1",
 				},
 				},
 				},
@@ -254,17 +257,39 @@ func TestCreateVolumeArguments(t *testing.T) {
 			expVol: &csi.Volume{
 				CapacityBytes: 20 * 1024 * 1024 * 1024, // In byte
 				VolumeId:      "testVolumeId",
-				VolumeContext: map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "myzone", VolumeIDLabel: "testVolumeId", Tag: "", VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
+				VolumeContext: map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "testregion-1", VolumeIDLabel: "testVolumeId", Tag: ownedTag, VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
 				AccessibleTopology: []*csi.Topology{
 					{
-						Segments: map[string]string{utils.NodeZoneLabel: "myzone", utils.NodeRegionLabel: "testregion"},
+						Segments: map[string]string{utils.NodeZoneLabel: "testregion-1", utils.NodeRegionLabel: "testregion"},
 					},
 				},
 			},
-			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "myzone", Region: "myregion"},
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
 			expErrCode:        codes.OK,
 			libVolumeError:    nil,
 		},
+		{
+			name: "Topology of other region than the driver region",
+			req: &csi.CreateVolumeRequest{
+				Name:               volName,
+				CapacityRange:      stdCapRange,
+				VolumeCapabilities: stdVolCap,
+				Parameters: map[string]string{
+					Profile: "general-purpose",
+				},
+				AccessibilityRequirements: &csi.TopologyRequirement{Preferred: []*csi.Topology{{Segments: map[string]string{
+					utils.NodeRegionLabel: "myregion",
+					utils.NodeZoneLabel:   "testregion-1",
+				},
+				},
+				},
+				},
+			},
+			expVol:            nil,
+			libVolumeResponse: nil,
+			expErrCode:        codes.InvalidArgument,
+			libVolumeError:    nil,
+		},
 		{
 			name: "Invalid sourcesnapshot request",
 			req: &csi.CreateVolumeRequest{
@@ -311,12 +336,20 @@ func TestCreateVolumeArguments(t *testing.T) {
 			expVol: &csi.Volume{
 				CapacityBytes:      20 * 1024 * 1024 * 1024, // In byte
 				VolumeId:           "testVolumeId",
-				VolumeContext:      map[string]string{utils.NodeRegionLabel: "myregion", utils.NodeZoneLabel: "myzone", VolumeIDLabel: "testVolumeId", Tag: "", VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
+				VolumeContext:      map[string]string{utils.NodeRegionLabel: "testregion", utils.NodeZoneLabel: "testregion-1", VolumeIDLabel: "testVolumeId", Tag: ownedTag, VolumeCRNLabel: "", ClusterIDLabel: "fake-clusterID"},
 				AccessibleTopology: stdTopology,
+				ContentSource: &csi.VolumeContentSource{
+					Type: &csi.VolumeContentSource_Snapshot{
+						Snapshot: &csi.VolumeContentSource_SnapshotSource{
+							SnapshotId: "snapshot-id",
+						},
+					},
+				},
 			},
-			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "myzone", Region: "myregion"},
-			expErrCode:        codes.OK,
-			libVolumeError:    nil,
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "testregion-1", Region: "testregion",
+				Snapshot: provider.Snapshot{SnapshotID: "snapshot-id"}, VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
+			expErrCode:     codes.OK,
+			libVolumeError: nil,
 		},
 	}
 
@@ -374,6 +407,282 @@ func TestCreateVolumeArguments(t *testing.T) {
 	}
 }
 
+func TestCreateVolumeClone(t *testing.T) {
+	cap := 20
+	largeCap := 30
+	volName := "test-name"
+	iopsStr := ""
+	cloneSource := &csi.VolumeContentSource{
+		Type: &csi.VolumeContentSource_Volume{
+			Volume: &csi.VolumeContentSource_VolumeSource{VolumeId: "source-volume-id"},
+		},
+	}
+	notFoundError := providerError.Message{Code: "StorageFindFailedWithVolumeName", Description: "Volume not found by name", Type: providerError.RetrivalFailed}
+	sourceVolume := &provider.Volume{Capacity: &cap, VolumeID: "source-volume-id", Az: "testregion-1"}
+	// test cases
+	backendError := providerError.Message{Code: "InternalError", Description: "Internal server error", RC: 500}
+	testCases := []struct {
+		name                   string
+		sourceVolume           *provider.Volume
+		sourceVolumeError      error
+		libSnapshotResponse    *provider.Snapshot
+		libSnapshotError       error
+		libSnapshotByNameError error
+		libGetSnapshotResponse *provider.Snapshot
+		expErrCode             codes.Code
+		expDeleteSnapshotCount int
+	}{
+		{
+			name:                   "Success clone volume",
+			sourceVolume:           sourceVolume,
+			libSnapshotResponse:    &provider.Snapshot{SnapshotID: "clone-snapshot-id", VolumeID: "source-volume-id", ReadyToUse: true},
+			expErrCode:             codes.OK,
+			expDeleteSnapshotCount: 1,
+		},
+		{
+			name:                   "Success clone volume after waiting for snapshot",
+			sourceVolume:           sourceVolume,
+			libSnapshotResponse:    &provider.Snapshot{SnapshotID: "clone-snapshot-id", VolumeID: "source-volume-id", ReadyToUse: false},
+			libGetSnapshotResponse: &provider.Snapshot{SnapshotID: "clone-snapshot-id", VolumeID: "source-volume-id", ReadyToUse: true},
+			expErrCode:             codes.OK,
+			expDeleteSnapshotCount: 1,
+		},
+		{
+			name:         "Source volume not found",
+			sourceVolume: nil,
+			expErrCode:   codes.NotFound,
+		},
+		{
+			name:              "Failed to get source volume",
+			sourceVolume:      nil,
+			sourceVolumeError: backendError,
+			expErrCode:        codes.Internal,
+		},
+		{
+			name:                   "Failed to find clone snapshot",
+			sourceVolume:           sourceVolume,
+			libSnapshotByNameError: backendError,
+			expErrCode:             codes.Internal,
+		},
+		{
+			name:         "Requested size less than source volume",
+			sourceVolume: &provider.Volume{Capacity: &largeCap, VolumeID: "source-volume-id", Az: "testregion-1"},
+			expErrCode:   codes.InvalidArgument,
+		},
+		{
+			name:             "Clone snapshot creation failed",
+			sourceVolume:     sourceVolume,
+			libSnapshotError: providerError.Message{Code: "SnapshotSpaceOrderFailed", Description: "Snapshot creation failed"},
+			expErrCode:       codes.Internal,
+		},
+	}
+
+	// Creating test logger
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	defer func(interval time.Duration) { volumeStatusPollInterval = interval }(volumeStatusPollInterval)
+	volumeStatusPollInterval = time.Millisecond
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Logf("test case: %s", tc.name)
+		// Setup new driver each time so no interference
+		icDriver := initIBMCSIDriver(t)
+
+		fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
+		assert.Nil(t, err)
+		fakeStructSession, ok := fakeSession.(*fake.FakeSession)
+		assert.Equal(t, true, ok)
+		fakeStructSession.GetVolumeByNameReturns(nil, notFoundError)
+		if tc.sourceVolume != nil {
+			fakeStructSession.GetVolumeReturns(tc.sourceVolume, nil)
+		} else if tc.sourceVolumeError != nil {
+			fakeStructSession.GetVolumeReturns(nil, tc.sourceVolumeError)
+		} else {
+			fakeStructSession.GetVolumeReturns(nil, notFoundError)
+		}
+		if tc.libSnapshotByNameError != nil {
+			fakeStructSession.GetSnapshotByNameReturnsOnCall(0, nil, tc.libSnapshotByNameError)
+		} else {
+			fakeStructSession.GetSnapshotByNameReturnsOnCall(0, nil, notFoundError)
+		}
+		fakeStructSession.GetSnapshotByNameReturnsOnCall(1, tc.libSnapshotResponse, nil)
+		fakeStructSession.CreateSnapshotReturns(tc.libSnapshotResponse, tc.libSnapshotError)
+		fakeStructSession.GetSnapshotReturns(tc.libGetSnapshotResponse, nil)
+		fakeStructSession.CreateVolumeReturns(&provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Iops: &iopsStr, Az: "testregion-1", Region: "testregion"}, nil)
+
+		// Call CSI CreateVolume
+		req := &csi.CreateVolumeRequest{Name: volName, CapacityRange: stdCapRange, VolumeCapabilities: stdVolCap, Parameters: stdParams, VolumeContentSource: cloneSource}
+		resp, err := icDriver.cs.CreateVolume(context.Background(), req)
+		assert.Equal(t, tc.expErrCode, status.Code(err))
+		assert.Equal(t, tc.expDeleteSnapshotCount, fakeStructSession.DeleteSnapshotCallCount())
+		if tc.expErrCode != codes.OK {
+			assert.Nil(t, resp)
+			continue
+		}
+		assert.Equal(t, cloneSource, resp.GetVolume().GetContentSource())
+		assert.Equal(t, "clone-snapshot-id", fakeStructSession.CreateVolumeArgsForCall(0).SnapshotID)
+		sourceVolumeID, snapshotParameters := fakeStructSession.CreateSnapshotArgsForCall(0)
+		assert.Equal(t, "source-volume-id", sourceVolumeID)
+		assert.Equal(t, getCloneSnapshotName(volName), snapshotParameters.Name)
+	}
+}
+
+func TestCreateVolumeWaitForAvailable(t *testing.T) {
+	cap := 20
+	volName := "test-name"
+	notFoundError := providerError.Message{Code: "StorageFindFailedWithVolumeName", Description: "Volume not found by name", Type: providerError.RetrivalFailed}
+	notValidStateError := providerError.Message{Code: VolumeNotInValidState, Description: "Volume did not get valid (available) status within timeout period."}
+	newVolume := func(status string) *provider.Volume {
+		vol := &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "testVolumeId", Az: "testregion-1"}
+		vol.Status = status
+		vol.CRN = "crn:" + status
+		vol.Tags = []string{ownedTag}
+		return vol
+	}
+	otherClusterVolume := newVolume(VolumeStatusFailed)
+	otherClusterVolume.Tags = []string{ClusterIDLabel + ":other-clusterID"}
+	otherProfileVolume := newVolume(VolumeStatusAvailable)
+	otherProfileVolume.Profile = &provider.Profile{Name: "10iops-tier"}
+	otherRequestVolume := newVolume(VolumeStatusFailed)
+	otherRequestVolume.Tags = append(otherRequestVolume.Tags, RequestNameLabel+":other-request")
+	templateParams := map[string]string{NameTemplate: "${pvc.namespace}-${pvc.name}", PVCNameKey: "data", PVCNamespaceKey: "db"}
+	for key, value := range stdParams {
+		templateParams[key] = value
+	}
+	// test cases
+	testCases := []struct {
+		name                 string
+		libExistingVolume    *provider.Volume
+		libCreateResponse    *provider.Volume
+		libCreateError       error
+		libGetVolumeResponse *provider.Volume
+		params               map[string]string
+		timeout              time.Duration
+		expErrCode           codes.Code
+		expCreateVolumeCount int
+		expDeleteVolumeCount int
+	}{
+		{
+			name:                 "Wait for created volume",
+			libCreateResponse:    newVolume(VolumeStatusPending),
+			libGetVolumeResponse: newVolume(VolumeStatusAvailable),
+			expErrCode:           codes.OK,
+			expCreateVolumeCount: 1,
+		},
+		{
+			name:                 "Resume waiting for in-flight volume",
+			libExistingVolume:    newVolume(VolumeStatusPending),
+			libGetVolumeResponse: newVolume(VolumeStatusAvailable),
+			expErrCode:           codes.OK,
+		},
+		{
+			name:                 "Resume waiting when provider gave up on the volume",
+			libCreateError:       notValidStateError,
+			libGetVolumeResponse: newVolume(VolumeStatusAvailable),
+			expErrCode:           codes.OK,
+			expCreateVolumeCount: 1,
+		},
+		{
+			name:                 "Failed volume of previous attempt is deleted",
+			libExistingVolume:    newVolume(VolumeStatusFailed),
+			libCreateResponse:    newVolume(VolumeStatusAvailable),
+			expErrCode:           codes.OK,
+			expCreateVolumeCount: 1,
+			expDeleteVolumeCount: 1,
+		},
+		{
+			name:              "Volume of the same name of other cluster is not used",
+			libExistingVolume: otherClusterVolume,
+			expErrCode:        codes.AlreadyExists,
+		},
+		{
+			name:              "Failed volume of the same name of other request is not deleted",
+			libExistingVolume: otherRequestVolume,
+			params:            templateParams,
+			expErrCode:        codes.AlreadyExists,
+		},
+		{
+			name:              "Volume of the same name with other profile is not used",
+			libExistingVolume: otherProfileVolume,
+			expErrCode:        codes.AlreadyExists,
+		},
+		{
+			name:                 "Created volume failed",
+			libCreateResponse:    newVolume(VolumeStatusPending),
+			libGetVolumeResponse: newVolume(VolumeStatusFailed),
+			expErrCode:           codes.Internal,
+			expCreateVolumeCount: 1,
+			expDeleteVolumeCount: 1,
+		},
+		{
+			name:                 "Request deadline exceeded while volume is pending",
+			libCreateResponse:    newVolume(VolumeStatusPending),
+			libGetVolumeResponse: newVolume(VolumeStatusPending),
+			timeout:              10 * time.Millisecond,
+			expErrCode:           codes.DeadlineExceeded,
+			expCreateVolumeCount: 1,
+		},
+	}
+
+	// Creating test logger
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	defer func(interval time.Duration) { volumeStatusPollInterval = interval }(volumeStatusPollInterval)
+	volumeStatusPollInterval = time.Millisecond
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Logf("test case: %s", tc.name)
+		// Setup new driver each time so no interference
+		icDriver := initIBMCSIDriver(t)
+
+		fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
+		assert.Nil(t, err)
+		fakeStructSession, ok := fakeSession.(*fake.FakeSession)
+		assert.Equal(t, true, ok)
+		if tc.libExistingVolume != nil {
+			fakeStructSession.GetVolumeByNameReturnsOnCall(0, tc.libExistingVolume, nil)
+		} else {
+			fakeStructSession.GetVolumeByNameReturnsOnCall(0, nil, notFoundError)
+		}
+		fakeStructSession.GetVolumeByNameReturnsOnCall(1, newVolume(VolumeStatusPending), nil)
+		fakeStructSession.CreateVolumeReturns(tc.libCreateResponse, tc.libCreateError)
+		fakeStructSession.GetVolumeReturns(tc.libGetVolumeResponse, nil)
+
+		ctx := context.Background()
+		if tc.timeout != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+			defer cancel()
+		}
+
+		params := stdParams
+		if tc.params != nil {
+			params = tc.params
+		}
+
+		// Call CSI CreateVolume
+		req := &csi.CreateVolumeRequest{Name: volName, CapacityRange: stdCapRange, VolumeCapabilities: stdVolCap, Parameters: params}
+		resp, err := icDriver.cs.CreateVolume(ctx, req)
+		assert.Equal(t, tc.expErrCode, status.Code(err))
+		assert.Equal(t, tc.expCreateVolumeCount, fakeStructSession.CreateVolumeCallCount())
+		assert.Equal(t, tc.expDeleteVolumeCount, fakeStructSession.DeleteVolumeCallCount())
+		if tc.expCreateVolumeCount > 0 {
+			assert.Contains(t, fakeStructSession.CreateVolumeArgsForCall(0).Tags, ownedTag)
+		}
+		if tc.expErrCode != codes.OK {
+			assert.Nil(t, resp)
+			continue
+		}
+		assert.Equal(t, "testVolumeId", resp.GetVolume().GetVolumeId())
+		// Response is of the volume waited for, not of the one created or found
+		assert.Equal(t, "crn:"+VolumeStatusAvailable, resp.GetVolume().GetVolumeContext()[VolumeCRNLabel])
+	}
+}
+
 func TestDeleteVolume(t *testing.T) {
 	// test cases
 	testCases := []struct {
@@ -383,13 +692,18 @@ func TestDeleteVolume(t *testing.T) {
 		expErrCode         codes.Code
 		libVolumeRespError error
 		libVolumeResponse  *provider.Volume
+		libGetVolumeError  error
+		libSnapshotError   error
+		allowUntagged      bool
+		expSnapshotCount   int
+		expDeleteCount     int
 	}{
 		{
 			name:              "Success volume delete",
 			req:               &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
 			expResponse:       &csi.DeleteVolumeResponse{},
 			expErrCode:        codes.OK,
-			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "myzone", Region: "myregion"},
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
 		},
 		{
 			name:        "Success volume delete in case volume not found",
@@ -407,9 +721,70 @@ func TestDeleteVolume(t *testing.T) {
 			name:               "Failed from lib volume delete failed",
 			req:                &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
 			expResponse:        nil,
-			expErrCode:         codes.Internal,
+			expErrCode:         codes.InvalidArgument,
 			libVolumeRespError: providerError.Message{Code: "FailedToDeleteVolume", Description: "Volume deletion failed", Type: providerError.DeletionFailed},
-			libVolumeResponse:  &provider.Volume{VolumeID: "testVolumeId", Az: "myzone", Region: "myregion"},
+			libVolumeResponse:  &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
+			expDeleteCount:     1,
+		},
+		{
+			name:              "Failed volume delete when volume lookup fails",
+			req:               &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse:       nil,
+			expErrCode:        codes.Internal,
+			libGetVolumeError: providerError.Message{Code: "InternalError", Description: "Internal server error", RC: 500},
+		},
+		{
+			name:        "Failed volume delete of other cluster",
+			req:         &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse: nil,
+			expErrCode:  codes.FailedPrecondition,
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ClusterIDLabel + ":other-clusterID"}}},
+		},
+		{
+			name:              "Failed volume delete of volume not tagged with cluster",
+			req:               &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse:       nil,
+			expErrCode:        codes.FailedPrecondition,
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion"},
+		},
+		{
+			name:              "Success volume delete of volume not tagged with cluster if allowed",
+			req:               &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse:       &csi.DeleteVolumeResponse{},
+			expErrCode:        codes.OK,
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion"},
+			allowUntagged:     true,
+			expDeleteCount:    1,
+		},
+		{
+			name:        "Success volume delete of shared volume of other cluster",
+			req:         &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse: &csi.DeleteVolumeResponse{},
+			expErrCode:  codes.OK,
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ClusterIDLabel + ":other-clusterID", SharedVolumeTag}}},
+			expDeleteCount: 1,
+		},
+		{
+			name:        "Success volume delete with snapshot on delete",
+			req:         &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse: &csi.DeleteVolumeResponse{},
+			expErrCode:  codes.OK,
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ownedTag, SnapshotOnDeleteTag}}},
+			expSnapshotCount: 1,
+			expDeleteCount:   1,
+		},
+		{
+			name:        "Failed snapshot on delete keeps the volume",
+			req:         &csi.DeleteVolumeRequest{VolumeId: "testVolumeId"},
+			expResponse: nil,
+			expErrCode:  codes.Internal,
+			libVolumeResponse: &provider.Volume{VolumeID: "testVolumeId", Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ownedTag, SnapshotOnDeleteTag}}},
+			libSnapshotError: providerError.Message{Code: "SnapshotSpaceOrderFailed", Description: "Snapshot creation failed", Type: providerError.ProvisioningFailed},
+			expSnapshotCount: 1,
 		},
 	}
 
@@ -423,6 +798,8 @@ func TestDeleteVolume(t *testing.T) {
 		// Setup new driver each time so no interference
 		icDriver := initIBMCSIDriver(t)
 
+		icDriver.cs.allowUntaggedVolumes = tc.allowUntagged
+
 		// Set the response for DeleteVolume
 		fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
 		assert.Nil(t, err)
@@ -430,14 +807,25 @@ func TestDeleteVolume(t *testing.T) {
 		assert.Equal(t, true, ok)
 		fakeStructSession.DeleteVolumeReturns(tc.libVolumeRespError)
 		fakeStructSession.GetVolumeByNameReturns(tc.libVolumeResponse, nil)
-		fakeStructSession.GetVolumeReturns(tc.libVolumeResponse, nil)
+		fakeStructSession.GetVolumeReturns(tc.libVolumeResponse, tc.libGetVolumeError)
+		fakeStructSession.CreateSnapshotReturns(&provider.Snapshot{SnapshotID: "snap-id", VolumeID: "testVolumeId", ReadyToUse: true}, tc.libSnapshotError)
 
 		// Call CSI CreateVolume
 		response, err := icDriver.cs.DeleteVolume(context.Background(), tc.req)
 		if tc.expErrCode != codes.OK {
 			assert.NotNil(t, err)
+			assert.Equal(t, tc.expErrCode, status.Code(err))
 		}
 		assert.Equal(t, tc.expResponse, response)
+		assert.Equal(t, 0, fakeStructSession.DetachVolumeCallCount())
+		if tc.expDeleteCount > 0 || tc.expErrCode == codes.FailedPrecondition || tc.expSnapshotCount > 0 {
+			assert.Equal(t, tc.expDeleteCount, fakeStructSession.DeleteVolumeCallCount())
+		}
+		assert.Equal(t, tc.expSnapshotCount, fakeStructSession.CreateSnapshotCallCount())
+		if tc.expSnapshotCount > 0 {
+			_, snapshotParameters := fakeStructSession.CreateSnapshotArgsForCall(0)
+			assert.Equal(t, getRetentionSnapshotName("fake-clusterID", "testVolumeId"), snapshotParameters.Name)
+		}
 	}
 }
 
@@ -452,7 +840,8 @@ func isPublishVolumeresponseEqual(expected *csi.ControllerPublishVolumeResponse,
 
 	return expected.PublishContext["volume-id"] == actual.PublishContext["volume-id"] &&
 		expected.PublishContext["node-id"] == actual.PublishContext["node-id"] &&
-		expected.PublishContext["device-path"] == actual.PublishContext["device-path"]
+		expected.PublishContext["device-path"] == actual.PublishContext["device-path"] &&
+		expected.PublishContext[PublishInfoSourceSnapshotID] == actual.PublishContext[PublishInfoSourceSnapshotID]
 }
 
 func TestControllerPublishVolume(t *testing.T) {
@@ -481,6 +870,15 @@ func TestControllerPublishVolume(t *testing.T) {
 			libVolumeResponse:      &provider.Volume{VolumeID: "vol123"},
 			libVolumeRespError:     nil,
 		},
+		{
+			name:                  "Success attachment of volume restored from snapshot",
+			req:                   &csi.ControllerPublishVolumeRequest{VolumeId: "vol123", NodeId: "node123", VolumeCapability: &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
+			expResponse:           &csi.ControllerPublishVolumeResponse{PublishContext: map[string]string{"attach-status": "", "device-path": "/tmp", "node-id": "node123", "volume-id": "vol123", PublishInfoSourceSnapshotID: "snapshot-id"}},
+			expErrCode:            codes.OK,
+			libAttachResponse:     &provider.VolumeAttachmentResponse{VolumeAttachmentRequest: provider.VolumeAttachmentRequest{VolumeID: "vol123", InstanceID: "node123", VPCVolumeAttachment: &provider.VolumeAttachment{DevicePath: "/tmp"}}},
+			libWaitAttachResponse: &provider.VolumeAttachmentResponse{VolumeAttachmentRequest: provider.VolumeAttachmentRequest{VolumeID: "vol123", InstanceID: "node123", VPCVolumeAttachment: &provider.VolumeAttachment{DevicePath: "/tmp"}}},
+			libVolumeResponse:     &provider.Volume{VolumeID: "vol123", Snapshot: provider.Snapshot{SnapshotID: "snapshot-id"}},
+		},
 		{
 			name:               "Failed AttachVolume library call for node not found",
 			req:                &csi.ControllerPublishVolumeRequest{VolumeId: "vol123", NodeId: "node123", VolumeCapability: &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}},
@@ -583,6 +981,37 @@ func TestControllerPublishVolume(t *testing.T) {
 	}
 }
 
+func TestControllerPublishVolumeInstanceNotFound(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	icDriver := initIBMCSIDriver(t)
+	fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	fakeStructSession, ok := fakeSession.(*fake.FakeSession)
+	assert.Equal(t, true, ok)
+	fakeStructSession.GetVolumeReturns(&provider.Volume{VolumeID: "vol123"}, nil)
+
+	// Instance not found by the lookup is refused before the attach
+	fakeStructSession.GetVolumeAttachmentReturns(nil, providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "Trace Code:trace-1, Code:not_found, Description:Instance not found, RC:404 Not Found"})
+	req := &csi.ControllerPublishVolumeRequest{VolumeId: "vol123", NodeId: "node123", VolumeCapability: &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER}}}
+	for i := 0; i < 2; i++ {
+		response, err := icDriver.cs.ControllerPublishVolume(context.Background(), req)
+		assert.Nil(t, response)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+	// Second publish to the node is refused from the cache
+	assert.Equal(t, 1, fakeStructSession.GetVolumeAttachmentCallCount())
+	assert.Equal(t, 0, fakeStructSession.AttachVolumeCallCount())
+
+	// Failed lookup is not taken as the instance not found
+	fakeStructSession.GetVolumeAttachmentReturns(nil, providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "Trace Code:trace-2, Code:internal_error, Description:Internal error, RC:500"})
+	req.NodeId = "node456"
+	_, err = icDriver.cs.ControllerPublishVolume(context.Background(), req)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 0, fakeStructSession.AttachVolumeCallCount())
+}
+
 func TestControllerUnpublishVolume(t *testing.T) {
 	// test cases
 	testCases := []struct {
@@ -782,6 +1211,7 @@ func TestListVolumes(t *testing.T) {
 		expectedErr     bool
 		expErrCode      codes.Code
 		libVolumeError  error
+		foreignVolumes  []*provider.Volume
 	}{
 		{
 			name:            "normal",
@@ -790,6 +1220,16 @@ func TestListVolumes(t *testing.T) {
 			expErrCode:      codes.OK,
 			libVolumeError:  nil,
 		},
+		{
+			name:            "volumes of other clusters are not listed",
+			maxEntries:      10,
+			expectedEntries: 11,
+			expErrCode:      codes.OK,
+			foreignVolumes: []*provider.Volume{
+				{VolumeID: "other-cluster-volume", Capacity: &limit, VPCVolume: provider.VPCVolume{Tags: []string{ClusterIDLabel + ":other-clusterid"}}},
+				{VolumeID: "untagged-volume", Capacity: &limit},
+			},
+		},
 		{
 			name:            "fine amount of entries",
 			maxEntries:      40,
@@ -854,6 +1294,7 @@ func TestListVolumes(t *testing.T) {
 		volList := &provider.VolumeList{}
 		if !tc.expectedErr {
 			volList = createVolume(maxEntries)
+			volList.Volumes = append(volList.Volumes, tc.foreignVolumes...)
 		}
 		fakeStructSession.ListVolumesReturns(volList, tc.libVolumeError)
 
@@ -883,18 +1324,78 @@ func TestListVolumes(t *testing.T) {
 }
 
 func TestGetCapacity(t *testing.T) {
+	cap := 10
+	zoneVolumes := &provider.VolumeList{Volumes: []*provider.Volume{{VolumeID: "vol-1", Capacity: &cap}, {VolumeID: "vol-2", Capacity: &cap}}}
+	zoneTopology := &csi.Topology{Segments: map[string]string{utils.NodeZoneLabel: "testregion-1"}}
 	// test cases
 	testCases := []struct {
-		name        string
-		req         *csi.GetCapacityRequest
-		expResponse *csi.GetCapacityResponse
-		expErrCode  codes.Code
+		name               string
+		req                *csi.GetCapacityRequest
+		volumeCountLimit   string
+		capacityLimit      string
+		libVolumesResponse *provider.VolumeList
+		libVolumesError    error
+		expResponse        *csi.GetCapacityResponse
+		expErrCode         codes.Code
 	}{
 		{
-			name:        "Success get capacity",
-			req:         &csi.GetCapacityRequest{},
+			name: "Success get capacity without limits",
+			req:  &csi.GetCapacityRequest{},
+			expResponse: &csi.GetCapacityResponse{
+				AvailableCapacity: MaximumVolumeSizeInBytes,
+				MaximumVolumeSize: wrapperspb.Int64(MaximumVolumeSizeInBytes),
+				MinimumVolumeSize: wrapperspb.Int64(utils.MinimumVolumeSizeInBytes),
+			},
+			expErrCode: codes.OK,
+		},
+		{
+			name: "Success get capacity for sdp profile",
+			req:  &csi.GetCapacityRequest{Parameters: map[string]string{Profile: SDPProfile}, AccessibleTopology: zoneTopology},
+			expResponse: &csi.GetCapacityResponse{
+				AvailableCapacity: MaximumSDPVolumeSizeInBytes,
+				MaximumVolumeSize: wrapperspb.Int64(MaximumSDPVolumeSizeInBytes),
+				MinimumVolumeSize: wrapperspb.Int64(MinimumSDPVolumeSizeInBytes),
+			},
+			expErrCode: codes.OK,
+		},
+		{
+			name:        "Invalid profile",
+			req:         &csi.GetCapacityRequest{Parameters: map[string]string{Profile: "wrong-profile"}},
 			expResponse: nil,
-			expErrCode:  codes.OK,
+			expErrCode:  codes.InvalidArgument,
+		},
+		{
+			name:               "Volume count limit reached in zone",
+			req:                &csi.GetCapacityRequest{Parameters: map[string]string{Profile: "general-purpose"}, AccessibleTopology: zoneTopology},
+			volumeCountLimit:   "2",
+			libVolumesResponse: zoneVolumes,
+			expResponse: &csi.GetCapacityResponse{
+				AvailableCapacity: 0,
+				MaximumVolumeSize: wrapperspb.Int64(0),
+				MinimumVolumeSize: wrapperspb.Int64(utils.MinimumVolumeSizeInBytes),
+			},
+			expErrCode: codes.OK,
+		},
+		{
+			name:               "Capacity limit in zone",
+			req:                &csi.GetCapacityRequest{Parameters: map[string]string{Profile: "general-purpose"}, AccessibleTopology: zoneTopology},
+			volumeCountLimit:   "10",
+			capacityLimit:      "100",
+			libVolumesResponse: zoneVolumes,
+			expResponse: &csi.GetCapacityResponse{
+				AvailableCapacity: 80 * utils.GiB,
+				MaximumVolumeSize: wrapperspb.Int64(80 * utils.GiB),
+				MinimumVolumeSize: wrapperspb.Int64(utils.MinimumVolumeSizeInBytes),
+			},
+			expErrCode: codes.OK,
+		},
+		{
+			name:            "List volumes failed",
+			req:             &csi.GetCapacityRequest{AccessibleTopology: zoneTopology},
+			capacityLimit:   "100",
+			libVolumesError: providerError.Message{Code: "ListVolumesFailed", Description: "Unable to fetch list of volumes", RC: 500},
+			expResponse:     nil,
+			expErrCode:      codes.Internal,
 		},
 	}
 
@@ -905,19 +1406,23 @@ func TestGetCapacity(t *testing.T) {
 	// Run test cases
 	for _, tc := range testCases {
 		t.Logf("test case: %s", tc.name)
+		t.Setenv("MAX_VOLUMES_PER_ZONE", tc.volumeCountLimit)
+		t.Setenv("MAX_CAPACITY_PER_ZONE_GB", tc.capacityLimit)
 		// Setup new driver each time so no interference
 		icDriver := initIBMCSIDriver(t)
 
 		fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
 		assert.Nil(t, err)
-		/*fakeStructSession*/ _, ok := fakeSession.(*fake.FakeSession)
+		fakeStructSession, ok := fakeSession.(*fake.FakeSession)
 		assert.Equal(t, true, ok)
+		fakeStructSession.ListVolumesReturns(tc.libVolumesResponse, tc.libVolumesError)
 
-		// Call CSI CreateVolume
+		// Call CSI GetCapacity
 		response, err := icDriver.cs.GetCapacity(context.Background(), tc.req)
 		if tc.expErrCode != codes.OK {
 			t.Logf("Error code")
 			assert.NotNil(t, err)
+			assert.Equal(t, tc.expErrCode, status.Code(err))
 		}
 		assert.Equal(t, tc.expResponse, response)
 	}
@@ -939,10 +1444,13 @@ func TestControllerGetCapabilities(t *testing.T) {
 					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME}}},
 					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME}}},
 					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_LIST_VOLUMES}}},
-					// &csi.ControllerServiceCapability{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_GET_CAPACITY}}},
+					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_GET_CAPACITY}}},
 					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT}}},
 					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS}}},
 					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_EXPAND_VOLUME}}},
+					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_GET_VOLUME}}},
+					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_VOLUME_CONDITION}}},
+					{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_CLONE_VOLUME}}},
 					// &csi.ControllerServiceCapability{Type: &csi.ControllerServiceCapability_Rpc{Rpc: &csi.ControllerServiceCapability_RPC{Type: csi.ControllerServiceCapability_RPC_PUBLISH_READONLY}}},
 				},
 			},
@@ -992,6 +1500,7 @@ func TestCreateSnapshot(t *testing.T) {
 		libSnapshotresponseErr       error
 		libSnapshotByNameResponse    *provider.Snapshot
 		libSnapshotByNameResponseErr error
+		snapshotDisabled             bool
 	}{
 		{
 			name: "Success create snapshot",
@@ -1084,9 +1593,57 @@ func TestCreateSnapshot(t *testing.T) {
 				SourceVolumeId: "testVolumeId",
 				Name:           "Snapshot-success",
 			},
-			expErrCode:             codes.Internal,
+			expErrCode:             codes.Unavailable,
+			libSnapshotResponse:    nil,
+			libSnapshotresponseErr: providerError.Message{Code: "SnapshotSpaceOrderFailed", Description: "Snapshot creation failed", Type: providerError.ProvisioningFailed, RC: 500},
+		},
+		{
+			name: "Create snapshot throttled by provider",
+			req: &csi.CreateSnapshotRequest{
+				SourceVolumeId: "testVolumeId",
+				Name:           "Snapshot-success",
+			},
+			expErrCode:             codes.Unavailable,
 			libSnapshotResponse:    nil,
-			libSnapshotresponseErr: providerError.Message{Code: "SnapshotSpaceOrderFailed", Description: "Snapshot creation failed", Type: providerError.ProvisioningFailed},
+			libSnapshotresponseErr: errors.New("Trace Code: a0e1e74b-4686-42df-8663-5634fe0d3241, Code: too_many_requests, Description: Too many requests, RC: 429 Too Many Requests"),
+		},
+		{
+			name: "Create snapshot failed due to invalid request",
+			req: &csi.CreateSnapshotRequest{
+				SourceVolumeId: "testVolumeId",
+				Name:           "Snapshot-success",
+			},
+			expErrCode:             codes.InvalidArgument,
+			libSnapshotResponse:    nil,
+			libSnapshotresponseErr: errors.New("Trace Code: a0e1e74b-4686-42df-8663-5634fe0d3241, Code: InvalidArgument, Description: Snapshot creation failed, RC: 400 Bad Request"),
+		},
+		{
+			name: "Create snapshot failed due to source volume not found",
+			req: &csi.CreateSnapshotRequest{
+				SourceVolumeId: "testVolumeId",
+				Name:           "Snapshot-success",
+			},
+			expErrCode:             codes.NotFound,
+			libSnapshotResponse:    nil,
+			libSnapshotresponseErr: providerError.Message{Code: "StorageFindFailedWithVolumeId", Description: "Volume not found", Type: providerError.RetrivalFailed},
+		},
+		{
+			name: "Create snapshot with invalid snapshot class parameter",
+			req: &csi.CreateSnapshotRequest{
+				SourceVolumeId: "testVolumeId",
+				Name:           "Snapshot-success",
+				Parameters:     map[string]string{Encrypted: "true"},
+			},
+			expErrCode: codes.InvalidArgument,
+		},
+		{
+			name: "Create snapshot disabled",
+			req: &csi.CreateSnapshotRequest{
+				SourceVolumeId: "testVolumeId",
+				Name:           "Snapshot-success",
+			},
+			snapshotDisabled: true,
+			expErrCode:       codes.Unimplemented,
 		},
 	}
 
@@ -1106,12 +1663,18 @@ func TestCreateSnapshot(t *testing.T) {
 		assert.Equal(t, true, ok)
 		fakeStructSession.CreateSnapshotReturns(tc.libSnapshotResponse, tc.libSnapshotresponseErr)
 		fakeStructSession.GetSnapshotByNameReturns(tc.libSnapshotByNameResponse, tc.libSnapshotByNameResponseErr)
+		if tc.snapshotDisabled {
+			t.Setenv("IS_SNAPSHOT_ENABLED", "false")
+		} else {
+			t.Setenv("IS_SNAPSHOT_ENABLED", "true")
+		}
 
 		// Call CSI CreateSnapshot
 		response, err := icDriver.cs.CreateSnapshot(context.Background(), tc.req)
 		if tc.expErrCode != codes.OK {
 			assert.NotNil(t, err)
 		}
+		assert.Equal(t, tc.expErrCode, status.Code(err))
 		assert.Equal(t, tc.expResponse, response)
 	}
 }
@@ -1126,6 +1689,7 @@ func TestDeleteSnapshot(t *testing.T) {
 		libGetSnapshotResponse         *provider.Snapshot
 		libGetSnapshotResponseErr      error
 		libDeleteSnapshotResponseError error
+		libVolumeResponse              *provider.Volume
 	}{
 		{
 			name: "Success delete snapshot",
@@ -1163,6 +1727,25 @@ func TestDeleteSnapshot(t *testing.T) {
 			libGetSnapshotResponseErr:      nil,
 			libDeleteSnapshotResponseError: providerError.Message{Code: "FailedToDeleteSnapshot", Description: "Snapshot deletion failed", Type: providerError.DeletionFailed},
 		},
+		{
+			name: "Delete snapshot of volume of other cluster",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: "snap-id",
+			},
+			expErrCode:             codes.FailedPrecondition,
+			libGetSnapshotResponse: &provider.Snapshot{SnapshotID: "snap-id", VolumeID: "vol-id"},
+			libVolumeResponse:      &provider.Volume{VolumeID: "vol-id", VPCVolume: provider.VPCVolume{Tags: []string{ClusterIDLabel + ":other-clusterID"}}},
+		},
+		{
+			name: "Delete snapshot of volume of the cluster",
+			req: &csi.DeleteSnapshotRequest{
+				SnapshotId: "snap-id",
+			},
+			expResponse:            &csi.DeleteSnapshotResponse{},
+			expErrCode:             codes.OK,
+			libGetSnapshotResponse: &provider.Snapshot{SnapshotID: "snap-id", VolumeID: "vol-id"},
+			libVolumeResponse:      &provider.Volume{VolumeID: "vol-id", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
+		},
 	}
 
 	// Creating test logger
@@ -1181,12 +1764,17 @@ func TestDeleteSnapshot(t *testing.T) {
 		assert.Equal(t, true, ok)
 		fakeStructSession.GetSnapshotReturns(tc.libGetSnapshotResponse, tc.libDeleteSnapshotResponseError)
 		fakeStructSession.DeleteSnapshotReturns(tc.libDeleteSnapshotResponseError)
+		fakeStructSession.GetVolumeReturns(tc.libVolumeResponse, nil)
 
 		// Call CSI DeleteSnapshot
 		response, err := icDriver.cs.DeleteSnapshot(context.Background(), tc.req)
 		if tc.expErrCode != codes.OK {
 			assert.NotNil(t, err)
 		}
+		if tc.expErrCode == codes.FailedPrecondition {
+			assert.Equal(t, tc.expErrCode, status.Code(err))
+			assert.Equal(t, 0, fakeStructSession.DeleteSnapshotCallCount())
+		}
 		assert.Equal(t, tc.expResponse, response)
 	}
 }
@@ -1277,6 +1865,7 @@ func TestControllerExpandVolume(t *testing.T) {
 	cap := 20
 	volName := "test-name"
 	iopsStr := ""
+	customIops := "100"
 	// test cases
 	testCases := []struct {
 		name                 string
@@ -1294,7 +1883,7 @@ func TestControllerExpandVolume(t *testing.T) {
 			expResponse:          &csi.ControllerExpandVolumeResponse{CapacityBytes: stdCapRange.RequiredBytes, NodeExpansionRequired: true},
 			expErrCode:           codes.OK,
 			libExpandResponse:    &http.Response{StatusCode: http.StatusOK},
-			libVolumeResponse:    &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &iopsStr, Az: "myzone", Region: "myregion"},
+			libVolumeResponse:    &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &iopsStr, Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
 			libExpandResponseErr: nil,
 			libVolumeError:       nil,
 		},
@@ -1324,12 +1913,36 @@ func TestControllerExpandVolume(t *testing.T) {
 			expResponse:       nil,
 			expErrCode:        codes.Internal,
 			libExpandResponse: nil,
-			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &iopsStr, Az: "myzone", Region: "myregion"},
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &iopsStr, Az: "testregion-1", Region: "testregion", VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}}},
 			libExpandResponseErr: providerError.Message{
 				Code: "FailedToPlaceOrder",
 			},
 			libVolumeError: providerError.Message{Code: "FailedToPlaceOrder", Description: "Volume expansion failed", Type: providerError.Unauthenticated},
 		},
+		{
+			name:        "Expand volume beyond the max size of the profile",
+			req:         &csi.ControllerExpandVolumeRequest{VolumeId: "volumeid", CapacityRange: &csi.CapacityRange{RequiredBytes: 16001 * utils.GiB}},
+			expResponse: nil,
+			expErrCode:  codes.InvalidArgument,
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &iopsStr, Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}, Profile: &provider.Profile{Name: "general-purpose"}}},
+		},
+		{
+			name:        "Expand custom volume to a size which does not support its iops",
+			req:         &csi.ControllerExpandVolumeRequest{VolumeId: "volumeid", CapacityRange: &csi.CapacityRange{RequiredBytes: 2000 * utils.GiB}},
+			expResponse: nil,
+			expErrCode:  codes.InvalidArgument,
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &customIops, Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}, Profile: &provider.Profile{Name: CustomProfile}}},
+		},
+		{
+			name:        "Expand volume of other cluster",
+			req:         &csi.ControllerExpandVolumeRequest{VolumeId: "volumeid", CapacityRange: stdCapRange},
+			expResponse: nil,
+			expErrCode:  codes.FailedPrecondition,
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", Iops: &iopsStr, Az: "testregion-1", Region: "testregion",
+				VPCVolume: provider.VPCVolume{Tags: []string{ClusterIDLabel + ":other-clusterID"}}},
+		},
 	}
 
 	// Creating test logger
@@ -1360,6 +1973,111 @@ func TestControllerExpandVolume(t *testing.T) {
 			assert.NotNil(t, err)
 		}
 		assert.Equal(t, tc.expResponse, response)
+		if (tc.expErrCode == codes.InvalidArgument || tc.expErrCode == codes.FailedPrecondition) && tc.libVolumeResponse != nil {
+			assert.Equal(t, tc.expErrCode, status.Code(err))
+			assert.Equal(t, 0, fakeStructSession.ExpandVolumeCallCount())
+		}
+	}
+}
+
+func TestControllerGetVolume(t *testing.T) {
+	cap := 20
+	volName := "test-name"
+	// test cases
+	testCases := []struct {
+		name              string
+		req               *csi.ControllerGetVolumeRequest
+		expResponse       *csi.ControllerGetVolumeResponse
+		expErrCode        codes.Code
+		libVolumeResponse *provider.Volume
+		libVolumeError    error
+	}{
+		{
+			name: "Success get available volume",
+			req:  &csi.ControllerGetVolumeRequest{VolumeId: "volumeid"},
+			expResponse: &csi.ControllerGetVolumeResponse{
+				Volume: &csi.Volume{VolumeId: "volumeid", CapacityBytes: int64(cap) * utils.GiB},
+				Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+					VolumeCondition: &csi.VolumeCondition{Abnormal: false, Message: "volume is available"},
+				},
+			},
+			expErrCode:        codes.OK,
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", VPCVolume: provider.VPCVolume{Status: "available"}},
+			libVolumeError:    nil,
+		},
+		{
+			name: "Success get failed volume",
+			req:  &csi.ControllerGetVolumeRequest{VolumeId: "volumeid"},
+			expResponse: &csi.ControllerGetVolumeResponse{
+				Volume: &csi.Volume{VolumeId: "volumeid", CapacityBytes: int64(cap) * utils.GiB},
+				Status: &csi.ControllerGetVolumeResponse_VolumeStatus{
+					VolumeCondition: &csi.VolumeCondition{Abnormal: true, Message: "volume is in 'failed' state"},
+				},
+			},
+			expErrCode:        codes.OK,
+			libVolumeResponse: &provider.Volume{Capacity: &cap, Name: &volName, VolumeID: "volumeid", VPCVolume: provider.VPCVolume{Status: "failed"}},
+			libVolumeError:    nil,
+		},
+		{
+			name:              "Nil volume ID",
+			req:               &csi.ControllerGetVolumeRequest{VolumeId: ""},
+			expResponse:       nil,
+			expErrCode:        codes.InvalidArgument,
+			libVolumeResponse: nil,
+			libVolumeError:    nil,
+		},
+		{
+			name:              "Volume not found",
+			req:               &csi.ControllerGetVolumeRequest{VolumeId: "volume-not-found-ID"},
+			expResponse:       nil,
+			expErrCode:        codes.NotFound,
+			libVolumeResponse: nil,
+			libVolumeError: providerError.Message{
+				Code:        "StorageFindFailedWithVolumeID",
+				Description: "Volume not found by volume ID",
+				Type:        providerError.RetrivalFailed,
+			},
+		},
+		{
+			name:              "Internal error while getting volume details",
+			req:               &csi.ControllerGetVolumeRequest{VolumeId: "volumeid"},
+			expResponse:       nil,
+			expErrCode:        codes.Internal,
+			libVolumeResponse: nil,
+			libVolumeError: providerError.Message{
+				Code:        "StorageFindFailed",
+				Description: "Internal error",
+				RC:          500,
+				Type:        providerError.PermissionDenied,
+			},
+		},
+	}
+
+	// Creating test logger
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	// Run test cases
+	for _, tc := range testCases {
+		t.Logf("test case: %s", tc.name)
+		// Setup new driver each time so no interference
+		icDriver := initIBMCSIDriver(t)
+
+		// Set the response for GetVolume
+		fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
+		assert.Nil(t, err)
+		fakeStructSession, ok := fakeSession.(*fake.FakeSession)
+		assert.Equal(t, true, ok)
+		fakeStructSession.GetVolumeReturns(tc.libVolumeResponse, tc.libVolumeError)
+
+		// Call CSI ControllerGetVolume
+		response, err := icDriver.cs.ControllerGetVolume(context.Background(), tc.req)
+		if tc.expErrCode != codes.OK {
+			t.Logf("Error code")
+			assert.NotNil(t, err)
+			assert.Equal(t, tc.expErrCode, status.Code(err))
+		}
+		assert.Equal(t, tc.expResponse, response)
 	}
 }
 
@@ -1369,10 +2087,11 @@ func createVolume(maxEntries int) *provider.VolumeList {
 	for i := 0; i <= maxEntries; i++ {
 		volName := "unit-test-volume" + strconv.Itoa(i)
 		vol := &provider.Volume{
-			VolumeID: fmt.Sprintf("vol-uuid-test-vol-%s", uuid.New().String()[:10]),
-			Name:     &volName,
-			Region:   "my-region",
-			Capacity: &cap,
+			VolumeID:  fmt.Sprintf("vol-uuid-test-vol-%s", uuid.New().String()[:10]),
+			Name:      &volName,
+			Region:    "my-region",
+			Capacity:  &cap,
+			VPCVolume: provider.VPCVolume{Tags: []string{ownedTag}},
 		}
 		if i == maxEntries {
 			volList.Next = vol.VolumeID
@@ -0,0 +1,226 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ibmcsidriver ...
+package ibmcsidriver
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// minDeviceSerialLen is the minimum length of a device serial, without separators, to be matched with
+// the device ID. Virtio truncates the serial to 20 chars so the serial can be a prefix of the device ID.
+const minDeviceSerialLen = 12
+
+// deviceIdentityFiles are the files under /sys/block/<device> holding the serial or WWN of the device,
+// virtio disks have the serial at the top level and NVMe namespaces under the controller
+var deviceIdentityFiles = []string{"serial", "device/serial", "wwid", "device/wwid"}
+
+// DeviceResolver finds the block device of a volume attached to the node from the serial or WWN of
+// the device, which is made of the device ID of the attachment. The paths are configurable so that the
+// resolution can be tested on a fake tree.
+type DeviceResolver struct {
+	devPath         string
+	devDiskByIDPath string
+	sysBlockPath    string
+}
+
+// NewDeviceResolver returns the device resolver of the node
+func NewDeviceResolver() *DeviceResolver {
+	return &DeviceResolver{
+		devPath:         "/dev",
+		devDiskByIDPath: "/dev/disk/by-id",
+		sysBlockPath:    "/sys/block",
+	}
+}
+
+// FindDevice returns the path of the device whose serial or WWN matches the device ID, or an empty path
+// if no device matches. It is an error if more than one device matches.
+func (resolver *DeviceResolver) FindDevice(deviceID string) (string, error) {
+	if len(normalizeDeviceID(deviceID)) == 0 {
+		return "", nil
+	}
+	devices, err := resolver.findDevicesByID(deviceID)
+	if err != nil {
+		return "", err
+	}
+	sysDevices, err := resolver.findDevicesBySysfs(deviceID)
+	if err != nil {
+		return "", err
+	}
+	for _, device := range sysDevices {
+		if !slices.Contains(devices, device) {
+			devices = append(devices, device)
+		}
+	}
+	switch len(devices) {
+	case 0:
+		return "", nil
+	case 1:
+		return devices[0], nil
+	default:
+		return "", fmt.Errorf("devices %v match the device ID %s", devices, deviceID)
+	}
+}
+
+// VerifyDevice checks that the serial or WWN of the device is of the device ID of the attachment, so that the
+// device of another volume is never formatted or mounted if the device names were shuffled. If no serial of the
+// device is readable, the device is verified by its /dev/disk/by-id link of the device ID instead and false is
+// returned for the caller to warn about it.
+func (resolver *DeviceResolver) VerifyDevice(devicePath string, deviceID string) (bool, error) {
+	device := devicePath
+	if realDevice, err := filepath.EvalSymlinks(devicePath); err == nil {
+		device = realDevice
+	}
+	var identifiers []string
+	for _, identityFile := range deviceIdentityFiles {
+		identifier, err := os.ReadFile(filepath.Join(resolver.sysBlockPath, filepath.Base(device), identityFile))
+		if err != nil {
+			continue
+		}
+		if matchesDeviceID(string(identifier), deviceID) {
+			return true, nil
+		}
+		identifiers = append(identifiers, strings.TrimSpace(string(identifier)))
+	}
+	if len(identifiers) != 0 {
+		return false, fmt.Errorf("serial %v of the device %s is not of the device ID %s", identifiers, device, deviceID)
+	}
+	devices, err := resolver.findDevicesByID(deviceID)
+	if err != nil {
+		return false, err
+	}
+	if !slices.Contains(devices, device) {
+		return false, fmt.Errorf("serial of the device %s is not found and it is not linked by the device ID %s in %s", device, deviceID, resolver.devDiskByIDPath)
+	}
+	return false, nil
+}
+
+// TriggerUdev replays the add events of the block devices only, for udev to create the links of the
+// devices attached without udev noticing them
+func (resolver *DeviceResolver) TriggerUdev(ctx context.Context) error {
+	out, err := exec.CommandContext(ctx, "udevadm", "trigger", "--action=add", "--subsystem-match=block").CombinedOutput() // #nosec G204: fixed arguments
+	if err != nil {
+		return fmt.Errorf("udevadm trigger failed, output %s, error: %v", string(out), err)
+	}
+	return nil
+}
+
+// findDevicesByID returns the devices linked from the /dev/disk/by-id entries matching the device ID,
+// like virtio-<serial> or nvme-<model>_<serial>
+func (resolver *DeviceResolver) findDevicesByID(deviceID string) ([]string, error) {
+	entries, err := os.ReadDir(resolver.devDiskByIDPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var devices []string
+	for _, entry := range entries {
+		// Partitions link to the partition devices
+		if strings.Contains(entry.Name(), "-part") {
+			continue
+		}
+		_, identifier, found := strings.Cut(entry.Name(), "-")
+		if !found || !matchesDeviceID(identifier, deviceID) {
+			continue
+		}
+		device, err := filepath.EvalSymlinks(filepath.Join(resolver.devDiskByIDPath, entry.Name()))
+		if err != nil {
+			// Dangling link of a detached device
+			continue
+		}
+		if !slices.Contains(devices, device) {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+// findDevicesBySysfs returns the devices whose serial or WWN in sysfs matches the device ID, which
+// finds the device even before udev has created its /dev/disk/by-id links
+func (resolver *DeviceResolver) findDevicesBySysfs(deviceID string) ([]string, error) {
+	entries, err := os.ReadDir(resolver.sysBlockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var devices []string
+	for _, entry := range entries {
+		for _, identityFile := range deviceIdentityFiles {
+			identifier, err := os.ReadFile(filepath.Join(resolver.sysBlockPath, entry.Name(), identityFile))
+			if err != nil || !matchesDeviceID(string(identifier), deviceID) {
+				continue
+			}
+			devices = append(devices, filepath.Join(resolver.devPath, entry.Name()))
+			break
+		}
+	}
+	return devices, nil
+}
+
+// matchesDeviceID checks if the serial or WWN of the device is of the device ID, the identifier may be a
+// serial truncated from the device ID, contain the device ID, or be a NVMe WWN with the serial hex encoded
+func matchesDeviceID(identifier string, deviceID string) bool {
+	normalizedDeviceID := normalizeDeviceID(deviceID)
+	for _, candidate := range deviceIdentifiers(identifier) {
+		serial := normalizeDeviceID(candidate)
+		if len(serial) < minDeviceSerialLen {
+			continue
+		}
+		if strings.HasPrefix(normalizedDeviceID, serial) || strings.Contains(serial, normalizedDeviceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceIdentifiers returns the identifier with the serials it may be made of, that is the tokens of
+// <model>_<serial> names and the hex decoded fields of nvme.<vendor>-<serial>-<model>-<namespace> WWNs
+func deviceIdentifiers(identifier string) []string {
+	identifier = strings.TrimSpace(identifier)
+	identifiers := []string{identifier}
+	identifiers = append(identifiers, strings.Split(identifier, "_")...)
+	if wwn, found := strings.CutPrefix(identifier, "nvme."); found {
+		for _, field := range strings.Split(wwn, "-") {
+			if decoded, err := hex.DecodeString(field); err == nil {
+				identifiers = append(identifiers, strings.TrimRight(string(decoded), " \x00"))
+			}
+		}
+	}
+	return identifiers
+}
+
+// normalizeDeviceID lower cases the ID and drops the separators, which the serials drop or replace
+func normalizeDeviceID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, id)
+}
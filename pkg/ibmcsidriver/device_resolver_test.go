@@ -0,0 +1,189 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ibmcsidriver
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeDeviceResolver returns a resolver on a fake tree of /dev and /sys/block, devices maps the
+// device names to their sysfs identity files, and links the /dev/disk/by-id entries to the device names
+func newFakeDeviceResolver(t *testing.T, devices map[string]map[string]string, links map[string]string) *DeviceResolver {
+	root := t.TempDir()
+	resolver := &DeviceResolver{
+		devPath:         filepath.Join(root, "dev"),
+		devDiskByIDPath: filepath.Join(root, "dev", "disk", "by-id"),
+		sysBlockPath:    filepath.Join(root, "sys", "block"),
+	}
+	assert.Nil(t, os.MkdirAll(resolver.devDiskByIDPath, 0755))
+	for device, identityFiles := range devices {
+		assert.Nil(t, os.WriteFile(filepath.Join(resolver.devPath, device), nil, 0600))
+		for identityFile, value := range identityFiles {
+			path := filepath.Join(resolver.sysBlockPath, device, identityFile)
+			assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+			assert.Nil(t, os.WriteFile(path, []byte(value+"\n"), 0600))
+		}
+	}
+	for link, device := range links {
+		assert.Nil(t, os.Symlink(filepath.Join("..", "..", device), filepath.Join(resolver.devDiskByIDPath, link)))
+	}
+	return resolver
+}
+
+func TestDeviceResolverFindDevice(t *testing.T) {
+	// Device IDs of the attachments, the serials and the device paths are made of the first 20 chars
+	attachmentDeviceID := "0717-2b8c6d4e-3f1a-4b2c-9d8e-7f6a5b4c3d2e-8xvsr"
+	otherAttachmentDeviceID := "0717-5e4d3c2b-1a09-4f8e-8d7c-6b5a4f3e2d1c-2kq9w"
+	deviceID := attachmentDeviceID[:20]
+	serial := attachmentDeviceID[:20]
+	otherSerial := otherAttachmentDeviceID[:20]
+	volumeID := "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	nvmeWWN := "nvme.1014-" + hex.EncodeToString([]byte(serial)) + "-" + hex.EncodeToString([]byte("IBM VPC Block")) + "-00000001"
+
+	testCases := []struct {
+		name       string
+		devices    map[string]map[string]string
+		links      map[string]string
+		deviceID   string
+		expDevice  string
+		expErrorOk bool
+	}{
+		{
+			name:      "Virtio device by serial link",
+			devices:   map[string]map[string]string{"vda": {}, "vdd": {}},
+			links:     map[string]string{"virtio-" + otherSerial: "vda", "virtio-" + serial: "vdd", "virtio-" + serial + "-part1": "vda"},
+			deviceID:  deviceID,
+			expDevice: "vdd",
+		},
+		{
+			name:      "Virtio device by sysfs serial before udev links",
+			devices:   map[string]map[string]string{"vda": {"serial": otherSerial}, "vdd": {"serial": serial}},
+			deviceID:  deviceID,
+			expDevice: "vdd",
+		},
+		{
+			name:      "Virtio device by the untruncated device ID",
+			devices:   map[string]map[string]string{"vda": {"serial": otherSerial}, "vdd": {"serial": serial}},
+			deviceID:  attachmentDeviceID,
+			expDevice: "vdd",
+		},
+		{
+			name:     "Serial is not of the volume ID",
+			devices:  map[string]map[string]string{"vdd": {"serial": serial}},
+			links:    map[string]string{"virtio-" + serial: "vdd"},
+			deviceID: volumeID,
+		},
+		{
+			name:      "NVMe device by serial link and sysfs",
+			devices:   map[string]map[string]string{"nvme0n1": {"device/serial": otherSerial}, "nvme1n1": {"device/serial": serial}},
+			links:     map[string]string{"nvme-IBM_VPC_Block_" + serial: "nvme1n1"},
+			deviceID:  deviceID,
+			expDevice: "nvme1n1",
+		},
+		{
+			name:      "NVMe device by hex encoded WWN",
+			devices:   map[string]map[string]string{"nvme1n1": {"wwid": nvmeWWN}},
+			deviceID:  deviceID,
+			expDevice: "nvme1n1",
+		},
+		{
+			name:     "Device not attached",
+			devices:  map[string]map[string]string{"vda": {"serial": otherSerial}},
+			links:    map[string]string{"virtio-" + otherSerial: "vda", "virtio-" + serial: "vdd"},
+			deviceID: deviceID,
+		},
+		{
+			name:     "Empty device ID",
+			devices:  map[string]map[string]string{"vda": {"serial": otherSerial}},
+			deviceID: "",
+		},
+		{
+			name:       "Device ID matching multiple devices",
+			devices:    map[string]map[string]string{"vdd": {"serial": serial}, "nvme1n1": {"device/serial": serial}},
+			deviceID:   deviceID,
+			expErrorOk: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resolver := newFakeDeviceResolver(t, tc.devices, tc.links)
+			device, err := resolver.FindDevice(tc.deviceID)
+			if tc.expErrorOk {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			if len(tc.expDevice) == 0 {
+				assert.Empty(t, device)
+				return
+			}
+			assert.Equal(t, filepath.Join(resolver.devPath, tc.expDevice), device)
+		})
+	}
+}
+
+func TestDeviceResolverVerifyDevice(t *testing.T) {
+	// Serials of the virtio devices are the device IDs of the attachments truncated to 20 chars
+	deviceID := "0717-2b8c6d4e-3f1a-4b2c-9d8e-7f6a5b4c3d2e-8xvsr"[:20]
+	otherDeviceID := "0717-5e4d3c2b-1a09-4f8e-8d7c-6b5a4f3e2d1c-2kq9w"[:20]
+	unreadableDeviceID := "0717-9c8b7a6f-5e4d-4c3b-a2a1-0f9e8d7c6b5a-4mz2t"[:20]
+	volumeID := "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	resolver := newFakeDeviceResolver(t, map[string]map[string]string{"vdd": {"serial": deviceID}, "vde": {"serial": otherDeviceID}, "vdf": {}, "vdg": {}},
+		map[string]string{"virtio-" + deviceID: "vdd", "virtio-" + otherDeviceID: "vde", "virtio-" + unreadableDeviceID: "vdf"})
+
+	verifiedBySerial, err := resolver.VerifyDevice(filepath.Join(resolver.devPath, "vdd"), deviceID)
+	assert.Nil(t, err)
+	assert.True(t, verifiedBySerial)
+	verifiedBySerial, err = resolver.VerifyDevice(filepath.Join(resolver.devDiskByIDPath, "virtio-"+deviceID), deviceID)
+	assert.Nil(t, err)
+	assert.True(t, verifiedBySerial)
+	// Serial is not of the volume ID
+	_, err = resolver.VerifyDevice(filepath.Join(resolver.devPath, "vdd"), volumeID)
+	assert.NotNil(t, err)
+	// Device names shuffled
+	_, err = resolver.VerifyDevice(filepath.Join(resolver.devPath, "vde"), deviceID)
+	assert.NotNil(t, err)
+	_, err = resolver.VerifyDevice(filepath.Join(resolver.devDiskByIDPath, "virtio-"+otherDeviceID), deviceID)
+	assert.NotNil(t, err)
+	// Device without serial is verified by its link of the device ID
+	verifiedBySerial, err = resolver.VerifyDevice(filepath.Join(resolver.devPath, "vdf"), unreadableDeviceID)
+	assert.Nil(t, err)
+	assert.False(t, verifiedBySerial)
+	// Device without serial not linked by the device ID
+	_, err = resolver.VerifyDevice(filepath.Join(resolver.devPath, "vdf"), deviceID)
+	assert.NotNil(t, err)
+	_, err = resolver.VerifyDevice(filepath.Join(resolver.devPath, "vdg"), deviceID)
+	assert.NotNil(t, err)
+}
+
+func TestMatchesDeviceID(t *testing.T) {
+	attachmentDeviceID := "0717-2b8c6d4e-3f1a-4b2c-9d8e-7f6a5b4c3d2e-8xvsr"
+	assert.True(t, matchesDeviceID(attachmentDeviceID[:20], attachmentDeviceID))
+	assert.True(t, matchesDeviceID(attachmentDeviceID[:20], attachmentDeviceID[:20]))
+	assert.True(t, matchesDeviceID("0717_2B8C6D4E_3F1A_4", attachmentDeviceID[:20]))
+	// Serial is not of the volume ID
+	assert.False(t, matchesDeviceID(attachmentDeviceID[:20], "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"))
+	// Serial too short to tell the devices apart
+	assert.False(t, matchesDeviceID("0717-2b8c", attachmentDeviceID))
+	assert.False(t, matchesDeviceID("eui.0025388b91c2a1f4", attachmentDeviceID))
+}
@@ -27,6 +27,8 @@ import (
 	cloudProvider "github.com/IBM/ibmcloud-volume-vpc/pkg/ibmcloudprovider"
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 )
 
 // IBMCSIDriver ...
@@ -51,7 +53,7 @@ func GetIBMCSIDriver() *IBMCSIDriver {
 }
 
 // SetupIBMCSIDriver ...
-func (icDriver *IBMCSIDriver) SetupIBMCSIDriver(provider cloudProvider.CloudProviderInterface, mounter mountManager.Mounter, statsUtil StatsUtils, metadata nodeMetadata.NodeMetadata, nodeInfo nodeMetadata.NodeInfo, lgr *zap.Logger, name, vendorVersion string) error {
+func (icDriver *IBMCSIDriver) SetupIBMCSIDriver(provider cloudProvider.CloudProviderInterface, mounter mountManager.Mounter, statsUtil StatsUtils, deviceUtil DeviceUtils, metadata nodeMetadata.NodeMetadata, nodeInfo nodeMetadata.NodeInfo, lgr *zap.Logger, name, vendorVersion string) error {
 	icDriver.logger = lgr
 	icDriver.logger.Info("IBMCSIDriver-SetupIBMCSIDriver setting up IBM CSI Driver...")
 
@@ -63,6 +65,10 @@ func (icDriver *IBMCSIDriver) SetupIBMCSIDriver(provider cloudProvider.CloudProv
 		return fmt.Errorf("mounter not initialized")
 	}
 
+	if deviceUtil == nil {
+		return fmt.Errorf("device utils not initialized")
+	}
+
 	if name == "" {
 		return fmt.Errorf("driver name missing")
 	}
@@ -84,11 +90,14 @@ func (icDriver *IBMCSIDriver) SetupIBMCSIDriver(provider cloudProvider.CloudProv
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
 		csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME,
 		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
-		// csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
 		csi.ControllerServiceCapability_RPC_CREATE_DELETE_SNAPSHOT,
 		csi.ControllerServiceCapability_RPC_LIST_SNAPSHOTS,
 		// csi.ControllerServiceCapability_RPC_PUBLISH_READONLY,
 		csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
+		csi.ControllerServiceCapability_RPC_GET_VOLUME,
+		csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+		csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
 	}
 	_ = icDriver.AddControllerServiceCapabilities(csc) // #nosec G104: Attempt to AddControllerServiceCapabilities only on best-effort basis.Error cannot be usefully handled.
 
@@ -101,7 +110,7 @@ func (icDriver *IBMCSIDriver) SetupIBMCSIDriver(provider cloudProvider.CloudProv
 
 	// Set up CSI RPC Servers
 	icDriver.ids = NewIdentityServer(icDriver)
-	icDriver.ns = NewNodeServer(icDriver, mounter, statsUtil, metadata)
+	icDriver.ns = NewNodeServer(icDriver, mounter, statsUtil, deviceUtil, metadata)
 	icDriver.cs = NewControllerServer(icDriver, provider)
 
 	icDriver.logger.Info("Successfully setup IBM CSI driver")
@@ -180,20 +189,29 @@ func NewIdentityServer(icDriver *IBMCSIDriver) *CSIIdentityServer {
 }
 
 // NewNodeServer ...
-func NewNodeServer(icDriver *IBMCSIDriver, mounter mountManager.Mounter, statsUtil StatsUtils, nodeMetadata nodeMetadata.NodeMetadata) *CSINodeServer {
+func NewNodeServer(icDriver *IBMCSIDriver, mounter mountManager.Mounter, statsUtil StatsUtils, deviceUtil DeviceUtils, nodeMetadata nodeMetadata.NodeMetadata) *CSINodeServer {
 	return &CSINodeServer{
 		Driver:   icDriver,
 		Mounter:  mounter,
 		Stats:    statsUtil,
+		Devices:  deviceUtil,
 		Metadata: nodeMetadata,
 	}
 }
 
+// SetEventRecorder sets the recorder of the events of the node server, recorded on the node object
+func (icDriver *IBMCSIDriver) SetEventRecorder(recorder record.EventRecorder, node *v1.ObjectReference) {
+	icDriver.ns.eventRecorder = recorder
+	icDriver.ns.node = node
+}
+
 // NewControllerServer ...
 func NewControllerServer(icDriver *IBMCSIDriver, provider cloudProvider.CloudProviderInterface) *CSIControllerServer {
 	return &CSIControllerServer{
-		Driver:      icDriver,
-		CSIProvider: provider,
+		Driver:               icDriver,
+		CSIProvider:          provider,
+		attachQueue:          attachQueue{maxConcurrent: getMaxConcurrentAttachPerNode(icDriver.logger)},
+		allowUntaggedVolumes: getAllowUntaggedVolumes(icDriver.logger),
 	}
 }
 
@@ -47,6 +47,7 @@ func initIBMCSIDriver(t *testing.T, fakeActions ...testingexec.FakeCommandAction
 	}
 
 	statsUtil := &MockStatUtils{}
+	deviceUtil := &MockDeviceUtils{}
 
 	fakeNodeData := nodeMetadata.FakeNodeMetadata{}
 	fakeNodeInfo := nodeInfo.FakeNodeInfo{}
@@ -56,7 +57,7 @@ func initIBMCSIDriver(t *testing.T, fakeActions ...testingexec.FakeCommandAction
 	fakeNodeInfo.NewNodeMetadataReturns(&fakeNodeData, nil)
 
 	// Setup the IBM CSI driver
-	err := icDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, &fakeNodeData, &fakeNodeInfo, logger, driver, vendorVersion)
+	err := icDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, deviceUtil, &fakeNodeData, &fakeNodeInfo, logger, driver, vendorVersion)
 	if err != nil {
 		t.Fatalf("Failed to setup IBM CSI Driver: %v", err)
 	}
@@ -81,6 +82,7 @@ func TestSetupIBMCSIDriver(t *testing.T) {
 	provider, _ := cloudProvider.NewFakeIBMCloudStorageProvider("", logger)
 	mounter := mountManager.NewFakeNodeMounter()
 	statsUtil := &MockStatUtils{}
+	deviceUtil := &MockDeviceUtils{}
 
 	fakeNodeData := nodeMetadata.FakeNodeMetadata{}
 	fakeNodeInfo := nodeInfo.FakeNodeInfo{}
@@ -90,14 +92,18 @@ func TestSetupIBMCSIDriver(t *testing.T) {
 	fakeNodeInfo.NewNodeMetadataReturns(&fakeNodeData, nil)
 
 	// Failed setting up driver, provider nil
-	err := icDriver.SetupIBMCSIDriver(nil, mounter, statsUtil, &fakeNodeData, &fakeNodeInfo, logger, name, vendorVersion)
+	err := icDriver.SetupIBMCSIDriver(nil, mounter, statsUtil, deviceUtil, &fakeNodeData, &fakeNodeInfo, logger, name, vendorVersion)
 	assert.NotNil(t, err)
 
 	// Failed setting up driver, mounter nil
-	err = icDriver.SetupIBMCSIDriver(provider, nil, statsUtil, &fakeNodeData, &fakeNodeInfo, logger, name, vendorVersion)
+	err = icDriver.SetupIBMCSIDriver(provider, nil, statsUtil, deviceUtil, &fakeNodeData, &fakeNodeInfo, logger, name, vendorVersion)
+	assert.NotNil(t, err)
+
+	// Failed setting up driver, device utils nil
+	err = icDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, nil, &fakeNodeData, &fakeNodeInfo, logger, name, vendorVersion)
 	assert.NotNil(t, err)
 
 	// Failed setting up driver, name empty
-	err = icDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, &fakeNodeData, &fakeNodeInfo, logger, "", vendorVersion)
+	err = icDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, deviceUtil, &fakeNodeData, &fakeNodeInfo, logger, "", vendorVersion)
 	assert.NotNil(t, err)
 }
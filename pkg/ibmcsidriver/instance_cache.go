@@ -0,0 +1,119 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ibmcsidriver ...
+package ibmcsidriver
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	providerError "github.com/IBM/ibmcloud-volume-interface/lib/utils"
+	"go.uber.org/zap"
+)
+
+// instanceCacheTTL is the time for which the result of an instance check is reused
+const instanceCacheTTL = 1 * time.Minute
+
+// attachmentNotFoundError is the backend error of the attachment lookup when the attachments of the instance
+// were listed but none is of the volume, i.e the instance exists
+const attachmentNotFoundError = "no VolumeAttachment Found"
+
+// instanceNotFoundCodes are the backend error codes of the attachment lookup when the instance does not exist,
+// not_found of VPC and worker(ST0005) or instance(P4106) not found of IKS
+var instanceNotFoundCodes = []string{"not_found", "ST0005", "P4106"}
+
+// attachmentNotFoundCodes are the backend error codes of the attachment lookup when the instance exists but
+// the volume is not attached to it, attachment(P4109) not found of IKS
+var attachmentNotFoundCodes = []string{"P4109"}
+
+// instanceCache remembers for a short while whether the instances exist, so that publishing to a stale
+// node fails fast instead of going through the retries of the attach call, and the publishing of the
+// volumes of a node does not look up the instance for each of them
+type instanceCache struct {
+	mutex   sync.Mutex
+	entries map[string]instanceCacheEntry
+}
+
+type instanceCacheEntry struct {
+	found  bool
+	expiry time.Time
+}
+
+// check returns whether the instance exists. The attachment of the volume is looked up on the instance,
+// which lists the attachments of the instance and fails if the instance is not found. An error is returned,
+// and nothing is cached, if the lookup fails for other reasons.
+func (ic *instanceCache) check(logger *zap.Logger, session provider.Session, volumeID string, instanceID string) (bool, error) {
+	if found, cached := ic.get(instanceID); cached {
+		return found, nil
+	}
+	_, err := session.GetVolumeAttachment(provider.VolumeAttachmentRequest{VolumeID: volumeID, InstanceID: instanceID})
+	found := true
+	if err != nil {
+		providerErr, _ := err.(providerError.Message)
+		code := getBackendErrorCode(providerErr.BackendError)
+		switch {
+		case providerErr.BackendError == attachmentNotFoundError || slices.Contains(attachmentNotFoundCodes, code):
+			// Instance exists, the volume is just not attached to it
+		case slices.Contains(instanceNotFoundCodes, code):
+			found = false
+		default:
+			logger.Warn("Failed to look up the instance", zap.String("InstanceID", instanceID), zap.Error(err))
+			return false, err
+		}
+	}
+	ic.set(instanceID, found)
+	return found, nil
+}
+
+// get returns the cached result of the instance, cached is false if there is none or it has expired
+func (ic *instanceCache) get(instanceID string) (found bool, cached bool) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	entry, ok := ic.entries[instanceID]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(entry.expiry) {
+		delete(ic.entries, instanceID)
+		return false, false
+	}
+	return entry.found, true
+}
+
+// set caches the result of the instance for instanceCacheTTL
+func (ic *instanceCache) set(instanceID string, found bool) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	if ic.entries == nil {
+		ic.entries = make(map[string]instanceCacheEntry)
+	}
+	ic.entries[instanceID] = instanceCacheEntry{found: found, expiry: time.Now().Add(instanceCacheTTL)}
+}
+
+// getBackendErrorCode returns the code of the backend error of the provider. Backend errors of VPC and IKS
+// are formatted as "Trace Code:<trace>, Code:<code>, Description:<description>, RC:<rc>".
+func getBackendErrorCode(backendError string) string {
+	for _, field := range strings.Split(backendError, ", ") {
+		if code, found := strings.CutPrefix(field, "Code:"); found {
+			return code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ibmcsidriver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
+	providerError "github.com/IBM/ibmcloud-volume-interface/lib/utils"
+	cloudProvider "github.com/IBM/ibmcloud-volume-vpc/pkg/ibmcloudprovider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceCache(t *testing.T) {
+	ic := &instanceCache{}
+	_, cached := ic.get("instance-1")
+	assert.False(t, cached)
+
+	ic.set("instance-1", false)
+	found, cached := ic.get("instance-1")
+	assert.True(t, cached)
+	assert.False(t, found)
+	_, cached = ic.get("instance-2")
+	assert.False(t, cached)
+
+	// Expired entries are dropped
+	ic.entries["instance-1"] = instanceCacheEntry{found: true, expiry: time.Now().Add(-time.Second)}
+	_, cached = ic.get("instance-1")
+	assert.False(t, cached)
+	assert.Empty(t, ic.entries)
+}
+
+func TestInstanceCacheCheck(t *testing.T) {
+	testCases := []struct {
+		name           string
+		lookupError    error
+		expFound       bool
+		expError       bool
+		expCached      bool
+		expCachedFound bool
+	}{
+		{
+			name:           "Volume attached to the instance",
+			expFound:       true,
+			expCached:      true,
+			expCachedFound: true,
+		},
+		{
+			name:           "Volume not attached to the instance",
+			lookupError:    providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "no VolumeAttachment Found"},
+			expFound:       true,
+			expCached:      true,
+			expCachedFound: true,
+		},
+		{
+			name:           "Volume not attached to the worker of IKS",
+			lookupError:    providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "Trace Code:incident-1, Code:P4109, Description:Volume attachment not found, RC:404"},
+			expFound:       true,
+			expCached:      true,
+			expCachedFound: true,
+		},
+		{
+			name:        "Instance not found",
+			lookupError: providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "Trace Code:trace-1, Code:not_found, Description:Instance not found, RC:404 Not Found"},
+			expCached:   true,
+		},
+		{
+			name:        "Worker of IKS not found",
+			lookupError: providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "Trace Code:incident-1, Code:ST0005, Description:Worker node could not be found, RC:404"},
+			expCached:   true,
+		},
+		{
+			name:        "Lookup failed",
+			lookupError: providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "Trace Code:trace-1, Code:internal_error, Description:Internal error, RC:500"},
+			expError:    true,
+		},
+		{
+			name:        "Lookup failed without backend error code",
+			lookupError: providerError.Message{Code: "VolumeAttachFindFailed", BackendError: "connection reset by peer"},
+			expError:    true,
+		},
+	}
+
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := &fake.FakeSession{}
+			session.GetVolumeAttachmentReturns(nil, tc.lookupError)
+			ic := &instanceCache{}
+
+			found, err := ic.check(logger, session, "vol-1", "instance-1")
+			assert.Equal(t, tc.expError, err != nil)
+			assert.Equal(t, tc.expFound, found)
+			found, cached := ic.get("instance-1")
+			assert.Equal(t, tc.expCached, cached)
+			assert.Equal(t, tc.expCachedFound, found)
+
+			// Cached result is reused for the other volumes of the instance
+			_, _ = ic.check(logger, session, "vol-2", "instance-1")
+			expLookups := 1
+			if !tc.expCached {
+				expLookups = 2
+			}
+			assert.Equal(t, expLookups, session.GetVolumeAttachmentCallCount())
+		})
+	}
+}
+
+func TestGetBackendErrorCode(t *testing.T) {
+	assert.Equal(t, "not_found", getBackendErrorCode("Trace Code:trace-1, Code:not_found, Description:Instance not found, RC:404 Not Found"))
+	assert.Equal(t, "P4106", getBackendErrorCode("Trace Code:incident-1, Code:P4106, Description:Instance not found, RC:404"))
+	assert.Equal(t, "", getBackendErrorCode("no VolumeAttachment Found"))
+}
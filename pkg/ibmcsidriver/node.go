@@ -23,10 +23,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 
 	"os/exec"
@@ -51,8 +52,14 @@ type CSINodeServer struct {
 	Mounter  mountmanager.Mounter
 	Metadata nodeMetadata.NodeMetadata
 	Stats    StatsUtils
-	// TODO: Only lock mutually exclusive calls and make locking more fine grained
-	mux sync.Mutex
+	Devices  DeviceUtils
+	// volumeLocks are the volumes and target paths with an operation in progress
+	volumeLocks volumeLocks
+	// formatLimiter bounds the number of volumes formatted at the same time
+	formatLimiter *formatLimiter
+	// eventRecorder records the events on the node object, no event is recorded if it is nil
+	eventRecorder record.EventRecorder
+	node          *v1.ObjectReference
 	csi.UnimplementedNodeServer
 }
 
@@ -64,6 +71,13 @@ type StatsUtils interface {
 	IsDevicePathNotExist(devicePath string) bool
 }
 
+// DeviceUtils ...
+type DeviceUtils interface {
+	FindDevice(deviceID string) (string, error)
+	VerifyDevice(devicePath string, deviceID string) (bool, error)
+	TriggerUdev(ctx context.Context) error
+}
+
 // VolumeStatUtils ...
 type VolumeStatUtils struct {
 }
@@ -109,8 +123,6 @@ func (csiNS *CSINodeServer) NodePublishVolume(ctx context.Context, req *csi.Node
 	ctxLogger, requestID := utils.GetContextLoggerWithRequestID(ctx, false, &controlleRequestID)
 	ctxLogger.Info("CSINodeServer-NodePublishVolume...", zap.Reflect("Request", req))
 	defer metrics.UpdateDurationFromStart(ctxLogger, "NodePublishVolume", time.Now())
-	csiNS.mux.Lock()
-	defer csiNS.mux.Unlock()
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -127,6 +139,13 @@ func (csiNS *CSINodeServer) NodePublishVolume(ctx context.Context, req *csi.Node
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoTargetPath, requestID, nil)
 	}
 
+	release, err := csiNS.lockVolume(volumeID, target)
+	if err != nil {
+		ctxLogger.Warn("NodePublishVolume rejected", zap.Error(err))
+		return nil, err
+	}
+	defer release()
+
 	volumeCapability := req.GetVolumeCapability()
 	if volumeCapability == nil {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoVolumeCapabilities, requestID, nil)
@@ -147,12 +166,14 @@ func (csiNS *CSINodeServer) NodePublishVolume(ctx context.Context, req *csi.Node
 	}
 	// Its OK if IsLikelyNotMountPoint returns PathNotExists error
 	if !notMounted {
-		// The  target Path is already mounted, Retrun OK
-		/* TODO
-		1) Target Path MUST be the vol referenced by vol ID
-		2) Check volume capability matches for ALREADY_EXISTS
-		3) Readonly MUST match
-		*/
+		// The target path is already mounted, return OK only if it is the mount of the volume as requested
+		mismatches, err := csiNS.getPublishedMountMismatches(ctx, ctxLogger, req)
+		if err != nil {
+			return nil, commonError.GetCSIError(ctxLogger, commonError.MountPointValidateError, requestID, err, target)
+		}
+		if len(mismatches) != 0 {
+			return nil, status.Errorf(codes.AlreadyExists, "Target path '%s' is already mounted with different attributes: %s", target, strings.Join(mismatches, ", "))
+		}
 		return &csi.NodePublishVolumeResponse{}, nil
 	}
 	// Perform a bind mount to the full path to allow duplicate mounts of the same PD.
@@ -168,7 +189,7 @@ func (csiNS *CSINodeServer) NodePublishVolume(ctx context.Context, req *csi.Node
 
 	switch volumeCapability.GetAccessType().(type) {
 	case *csi.VolumeCapability_Block:
-		nodePublishResponse, mountErr = csiNS.processMountForBlock(ctxLogger, requestID, publishContext[PublishInfoDevicePath], target, volumeID, options)
+		nodePublishResponse, mountErr = csiNS.processMountForBlock(ctx, ctxLogger, requestID, publishContext[PublishInfoDevicePath], getDeviceID(publishContext, volumeID), target, volumeID, options)
 
 	case *csi.VolumeCapability_Mount:
 		nodePublishResponse, mountErr = csiNS.processMount(ctxLogger, requestID, source, target, fsType, options)
@@ -183,8 +204,6 @@ func (csiNS *CSINodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.No
 	ctxLogger, requestID := utils.GetContextLogger(ctx, false)
 	ctxLogger.Info("CSINodeServer-NodeUnpublishVolume...", zap.Reflect("Request", req))
 	defer metrics.UpdateDurationFromStart(ctxLogger, "NodeUnpublishVolume", time.Now())
-	csiNS.mux.Lock()
-	defer csiNS.mux.Unlock()
 	// Validate Arguments
 	targetPath := req.GetTargetPath()
 	volID := req.GetVolumeId()
@@ -195,8 +214,15 @@ func (csiNS *CSINodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.No
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoTargetPath, requestID, nil)
 	}
 
+	release, err := csiNS.lockVolume(volID, targetPath)
+	if err != nil {
+		ctxLogger.Warn("NodeUnpublishVolume rejected", zap.Error(err))
+		return nil, err
+	}
+	defer release()
+
 	ctxLogger.Info("Unmounting  target path", zap.String("targetPath", targetPath))
-	err := mount.CleanupMountPoint(targetPath, csiNS.Mounter, false /* bind mount */)
+	err = mount.CleanupMountPoint(targetPath, csiNS.Mounter, false /* bind mount */)
 	if err != nil {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.UnmountFailed, requestID, err, targetPath)
 	}
@@ -214,9 +240,6 @@ func (csiNS *CSINodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeSt
 	ctxLogger.Info("CSINodeServer-NodeStageVolume...", zap.Reflect("Request", req))
 	defer metrics.UpdateDurationFromStart(ctxLogger, "NodeStageVolume", time.Now())
 
-	csiNS.mux.Lock()
-	defer csiNS.mux.Unlock()
-
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.EmptyVolumeID, requestID, nil)
@@ -225,6 +248,14 @@ func (csiNS *CSINodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeSt
 	if len(stagingTargetPath) == 0 {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoStagingTargetPath, requestID, nil)
 	}
+
+	release, err := csiNS.lockVolume(volumeID, stagingTargetPath)
+	if err != nil {
+		ctxLogger.Warn("NodeStageVolume rejected", zap.Error(err))
+		return nil, err
+	}
+	defer release()
+
 	volumeCapability := req.GetVolumeCapability()
 	if volumeCapability == nil || volumeCapability.AccessMode.GetMode() == csi.VolumeCapability_AccessMode_UNKNOWN {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoVolumeCapabilities, requestID, nil)
@@ -250,12 +281,23 @@ func (csiNS *CSINodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeSt
 		return nil, commonError.GetCSIError(ctxLogger, commonError.EmptyDevicePath, requestID, nil)
 	}
 	// Check source Path
-	source, err := csiNS.findDevicePathSource(ctxLogger, devicePath, volumeID)
+	deviceID := getDeviceID(publishContext, volumeID)
+	source, err := csiNS.findDevicePathSource(ctx, ctxLogger, devicePath, deviceID)
 	if err != nil {
-		return nil, commonError.GetCSIError(ctxLogger, commonError.DevicePathFindFailed, requestID, nil, devicePath)
+		return nil, commonError.GetCSIError(ctxLogger, commonError.DevicePathFindFailed, requestID, err, devicePath)
 	}
 	ctxLogger.Info("Found device path ", zap.String("devicePath", devicePath), zap.String("source", source))
 
+	// Device must be of the volume and must have a filesystem if the volume was restored from a snapshot
+	if err = csiNS.verifyDeviceIdentity(ctxLogger, source, volumeID, deviceID); err != nil {
+		return nil, err
+	}
+	if req.GetVolumeContext()[FormatRestoredVolume] != TrueStr {
+		if err = csiNS.checkRestoredFilesystem(ctxLogger, source, volumeID, publishContext[PublishInfoSourceSnapshotID]); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check target path
 	exists, err := csiNS.Mounter.PathExists(stagingTargetPath)
 	if err != nil {
@@ -294,8 +336,14 @@ func (csiNS *CSINodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeSt
 	options := collectMountOptions(fsType, mnt.MountFlags)
 
 	// FormatAndMount will format only if needed
+	// the format slot is taken for mounting as well as it is known only in FormatAndMount if a format is needed
+	releaseFormat, err := csiNS.formatLimiter.acquire(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.DeadlineExceeded, "Timed out waiting to format and mount '%s': %v", source, err)
+	}
 	ctxLogger.Info("Formating and mounting ", zap.String("source", source), zap.String("stagingTargetPath", stagingTargetPath), zap.String("fsType", fsType), zap.Reflect("options", options))
 	err = csiNS.Mounter.GetSafeFormatAndMount().FormatAndMount(source, stagingTargetPath, fsType, options)
+	releaseFormat()
 	if err != nil {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.FormatAndMountFailed, requestID, err, source, stagingTargetPath)
 	}
@@ -313,8 +361,6 @@ func (csiNS *CSINodeServer) NodeUnstageVolume(ctx context.Context, req *csi.Node
 	ctxLogger, requestID := utils.GetContextLogger(ctx, false)
 	ctxLogger.Info("CSINodeServer-NodeUnstageVolume ... ", zap.Reflect("Request", req))
 	defer metrics.UpdateDurationFromStart(ctxLogger, "NodeUnstageVolume", time.Now())
-	csiNS.mux.Lock()
-	defer csiNS.mux.Unlock()
 
 	// Validate arguments
 	volumeID := req.GetVolumeId()
@@ -326,8 +372,15 @@ func (csiNS *CSINodeServer) NodeUnstageVolume(ctx context.Context, req *csi.Node
 		return nil, commonError.GetCSIError(ctxLogger, commonError.NoStagingTargetPath, requestID, nil)
 	}
 
+	release, err := csiNS.lockVolume(volumeID, stagingTargetPath)
+	if err != nil {
+		ctxLogger.Warn("NodeUnstageVolume rejected", zap.Error(err))
+		return nil, err
+	}
+	defer release()
+
 	ctxLogger.Info("Unmounting staging target path", zap.String("stagingTargetPath", stagingTargetPath))
-	err := mount.CleanupMountPoint(stagingTargetPath, csiNS.Mounter, false /* bind mount */)
+	err = mount.CleanupMountPoint(stagingTargetPath, csiNS.Mounter, false /* bind mount */)
 	if err != nil {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.UnmountFailed, requestID, err, stagingTargetPath)
 	}
@@ -18,40 +18,109 @@ limitations under the License.
 package ibmcsidriver
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"time"
 
 	commonError "github.com/IBM/ibm-csi-common/pkg/messages"
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	mount "k8s.io/mount-utils"
 )
 
-// findDevicePath finds path of device and verifies its existence
-func (csiNS *CSINodeServer) findDevicePathSource(ctxLogger *zap.Logger, devicePath string, volumeID string /*TODO may be required in future*/) (string, error) {
-	ctxLogger.Info("CSINodeServer-findDevicePathSource...")
-	exists, err := csiNS.Mounter.PathExists(devicePath)
-	if err != nil || !exists {
-		ctxLogger.Warn("Device path not found, trying to fix by udevadm trigger", zap.String("DevicePath", devicePath))
-		if err = csiNS.udevadmTrigger(ctxLogger); err != nil {
-			ctxLogger.Error("Failed to execute udevadm trigger, will try to check device path again", zap.Error(err))
+// deviceWaitTimeout is the time to wait for the device of the volume if the request has no deadline
+var deviceWaitTimeout = 1 * time.Minute
+
+// deviceWaitInitialBackoff and deviceWaitMaxBackoff bound the interval between two lookups of the device
+var (
+	deviceWaitInitialBackoff = 200 * time.Millisecond
+	deviceWaitMaxBackoff     = 5 * time.Second
+)
+
+// getDeviceID returns the device ID of the attachment from the publish context, which the serial of the device
+// of the volume is made of. Publish contexts without the device ID have the virtio device path made of it, and
+// the volume ID is taken if neither has it.
+func getDeviceID(publishContext map[string]string, volumeID string) string {
+	if deviceID := publishContext[PublishInfoDeviceID]; len(deviceID) != 0 {
+		return deviceID
+	}
+	if deviceID := getAttachmentDeviceID(publishContext[PublishInfoDevicePath]); len(deviceID) != 0 {
+		return deviceID
+	}
+	return volumeID
+}
+
+// findDevicePathSource finds the device of the volume from the device ID of its attachment, the device path of
+// the publish context is only a hint as it is not the path of the device on the instance profiles with NVMe
+// disks. If the device is not there yet, it waits for the device till the deadline of the request.
+func (csiNS *CSINodeServer) findDevicePathSource(ctx context.Context, ctxLogger *zap.Logger, devicePath string, deviceID string) (string, error) {
+	ctxLogger.Info("CSINodeServer-findDevicePathSource...", zap.String("DevicePath", devicePath), zap.String("DeviceID", deviceID))
+	source, err := csiNS.resolveDevicePath(ctxLogger, devicePath, deviceID)
+	if err == nil && len(source) != 0 {
+		return source, nil
+	}
+	ctxLogger.Warn("Device of the volume not found, waiting for the device", zap.String("DevicePath", devicePath), zap.Error(err))
+	return csiNS.waitForDevice(ctx, ctxLogger, devicePath, deviceID)
+}
+
+// waitForDevice looks up the device of the volume with exponential backoff till it is found or the context
+// is done. Udev is triggered once, for the links of the device to be created if udev missed its events.
+func (csiNS *CSINodeServer) waitForDevice(ctx context.Context, ctxLogger *zap.Logger, devicePath string, deviceID string) (string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deviceWaitTimeout)
+		defer cancel()
+	}
+	if err := csiNS.Devices.TriggerUdev(ctx); err != nil {
+		ctxLogger.Warn("Failed to execute udevadm trigger, will keep looking for the device", zap.Error(err))
+	}
+
+	backoff := deviceWaitInitialBackoff
+	for {
+		source, err := csiNS.resolveDevicePath(ctxLogger, devicePath, deviceID)
+		if err == nil && len(source) != 0 {
+			ctxLogger.Info("Device of the volume found", zap.String("DevicePath", devicePath), zap.String("Source", source))
+			return source, nil
 		}
-		// Re-verifying device path and returning error accordingly
-		exists, err = csiNS.Mounter.PathExists(devicePath)
-		if err != nil {
-			return "", err
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("device of the device ID %s not found and device path %s does not exist: %v", deviceID, devicePath, ctx.Err())
+		case <-time.After(backoff):
 		}
+		backoff = min(2*backoff, deviceWaitMaxBackoff)
+	}
+}
+
+// resolveDevicePath returns the device whose serial or WWN matches the device ID, else the device path
+// of the publish context if it exists. It returns an empty path if the device is not found.
+func (csiNS *CSINodeServer) resolveDevicePath(ctxLogger *zap.Logger, devicePath string, deviceID string) (string, error) {
+	source, err := csiNS.Devices.FindDevice(deviceID)
+	if err != nil {
+		return "", err
+	}
+	if len(source) != 0 {
+		if source != devicePath {
+			ctxLogger.Info("Device of the volume differs from the device path of the publish context", zap.String("DevicePath", devicePath), zap.String("Source", source))
+		}
+		return source, nil
+	}
+	exists, err := csiNS.Mounter.PathExists(devicePath)
+	if err != nil {
+		return "", err
 	}
-	// If the path exists, assume it is not nvme device
 	if exists {
 		return devicePath, nil
 	}
-	ctxLogger.Warn("Device Path is nvme. Try to find nvme device")
-	return devicePath, nil
-	// TODO  Find NVMe path. Currently volume provider instance does not have NVMe
-	//For example, /dev/disk/by-uuid/e75b09ee-27d5-491a-85cd-c380f0e8ef5e -> ../../nvme2n1
+	return "", nil
 }
 
 func (csiNS *CSINodeServer) processMount(ctxLogger *zap.Logger, requestID, stagingTargetPath, targetPath, fsType string, options []string) (*csi.NodePublishVolumeResponse, error) {
@@ -98,7 +167,7 @@ func (csiNS *CSINodeServer) processMount(ctxLogger *zap.Logger, requestID, stagi
 // The mountType is "bind" mount and will not specify any FORMAT(e.g ext4, ext3..)
 // e.g SOURCE (volume provider attached device on Host): /dev/xvde
 // e.g TARGET (SoftLink to User defined POD device /dev/sda) : "/var/data/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pvc-9b82dced-fcd6-4181-968e-ae269e0f2311"
-func (csiNS *CSINodeServer) processMountForBlock(ctxLogger *zap.Logger, requestID, devicePath, target, volumeID string, options []string) (*csi.NodePublishVolumeResponse, error) {
+func (csiNS *CSINodeServer) processMountForBlock(ctx context.Context, ctxLogger *zap.Logger, requestID, devicePath, deviceID, target, volumeID string, options []string) (*csi.NodePublishVolumeResponse, error) {
 	ctxLogger.Info("CSINodeServer-processMountForBlock", zap.String("devicePath", devicePath), zap.String("target", target), zap.Reflect("options", options))
 
 	//get devicepath to be used as mountpoint source
@@ -106,11 +175,14 @@ func (csiNS *CSINodeServer) processMountForBlock(ctxLogger *zap.Logger, requestI
 		return nil, commonError.GetCSIError(ctxLogger, commonError.EmptyDevicePath, requestID, nil)
 	}
 	// Check source Path existence
-	source, err := csiNS.findDevicePathSource(ctxLogger, devicePath, volumeID)
+	source, err := csiNS.findDevicePathSource(ctx, ctxLogger, devicePath, deviceID)
 	if err != nil {
 		return nil, commonError.GetCSIError(ctxLogger, commonError.DevicePathFindFailed, requestID, err, devicePath)
 	}
 	ctxLogger.Info("Found device path ", zap.String("devicePath", devicePath), zap.String("source", source))
+	if err = csiNS.verifyDeviceIdentity(ctxLogger, source, volumeID, deviceID); err != nil {
+		return nil, err
+	}
 
 	targetDir := filepath.Dir(target)
 	exists, err := csiNS.Mounter.PathExists(targetDir)
@@ -146,22 +218,137 @@ func (csiNS *CSINodeServer) processMountForBlock(ctxLogger *zap.Logger, requestI
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
-func (csiNS *CSINodeServer) udevadmTrigger(ctxLogger *zap.Logger) error {
-	ctxLogger.Info("CSINodeServer-udevadmTrigger refreshing all devices...")
-	out, err := exec.Command(
-		"udevadm",
-		"trigger").CombinedOutput()
+// verifyDeviceIdentity checks that the device is of the attachment of the volume before it is formatted or
+// mounted, a device of another volume fails with FailedPrecondition and is recorded as an event of the node.
+// A device verified by its /dev/disk/by-id link only, as its serial is not readable, is recorded as a warning.
+func (csiNS *CSINodeServer) verifyDeviceIdentity(ctxLogger *zap.Logger, source string, volumeID string, deviceID string) error {
+	verifiedBySerial, err := csiNS.Devices.VerifyDevice(source, deviceID)
 	if err != nil {
-		return fmt.Errorf("udevadmTrigger: udevadm trigger failed, output %s, error: %v", string(out), err)
+		ctxLogger.Error("Device is not of the volume", zap.String("Source", source), zap.String("VolumeID", volumeID), zap.String("DeviceID", deviceID), zap.Error(err))
+		csiNS.recordWarningEvent(DeviceIdentityMismatch, "Device %s was not mounted for volume %s: %v", source, volumeID, err)
+		return status.Errorf(codes.FailedPrecondition, "Device '%s' is not the device of the volume '%s': %v", source, volumeID, err)
+	}
+	if !verifiedBySerial {
+		ctxLogger.Warn("Serial of the device is not readable, device is verified by its link of the device ID", zap.String("Source", source), zap.String("VolumeID", volumeID), zap.String("DeviceID", deviceID))
+		csiNS.recordWarningEvent(DeviceIdentityUnverified, "Serial of device %s of volume %s is not readable, the device is verified by its /dev/disk/by-id link of device ID %s only", source, volumeID, deviceID)
 	}
+	return nil
+}
 
-	// Sleep for 20 seconds so that udevadm trigger will do its magic
-	duration, err := time.ParseDuration("20s")
+// checkRestoredFilesystem refuses to format the volume restored from a snapshot if no filesystem is found on
+// its device, as formatting would hide the snapshot data which may not be readable yet. Storage classes of the
+// snapshots without filesystem skip the check with FormatRestoredVolume.
+func (csiNS *CSINodeServer) checkRestoredFilesystem(ctxLogger *zap.Logger, source string, volumeID string, snapshotID string) error {
+	if len(snapshotID) == 0 {
+		return nil
+	}
+	format, err := csiNS.Mounter.GetSafeFormatAndMount().GetDiskFormat(source)
 	if err != nil {
-		ctxLogger.Warn("udevadmTrigger: time.ParseDuration failed", zap.Error(err))
+		return status.Errorf(codes.Internal, "Failed to find the filesystem of the device '%s': %v", source, err)
+	}
+	if len(format) != 0 {
+		return nil
 	}
-	time.Sleep(duration)
+	ctxLogger.Error("Volume restored from snapshot has no filesystem", zap.String("Source", source), zap.String("VolumeID", volumeID), zap.String("SnapshotID", snapshotID))
+	csiNS.recordWarningEvent(SnapshotRestoreWithoutFilesystem, "Volume %s restored from snapshot %s was not formatted as no filesystem was found on device %s", volumeID, snapshotID, source)
+	return status.Errorf(codes.DataLoss, "Volume '%s' restored from the snapshot '%s' has no filesystem on the device '%s', refusing to format it. Set '%s' to true in the storage class if the snapshot has no filesystem",
+		volumeID, snapshotID, source, FormatRestoredVolume)
+}
 
-	ctxLogger.Info("udevadmTrigger: Successfully executed udevadm trigger to referesh all devices.")
-	return nil
+// recordWarningEvent records a warning event of the node, the node requests do not have the PV to record
+// the event on
+func (csiNS *CSINodeServer) recordWarningEvent(reason string, messageFmt string, args ...interface{}) {
+	if csiNS.eventRecorder == nil || csiNS.node == nil {
+		return
+	}
+	csiNS.eventRecorder.Eventf(csiNS.node, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// procMountInfoPath is the mountinfo of the node, which has the root of the bind mounts unlike /proc/mounts
+var procMountInfoPath = "/proc/self/mountinfo"
+
+// getMountInfo returns the mountinfo of the top mount on the path, nil if the path is not a mount point
+func getMountInfo(path string) (*mount.MountInfo, error) {
+	infos, err := mount.ParseMountInfo(procMountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = realPath
+	}
+	var mountInfo *mount.MountInfo
+	for i := range infos {
+		if infos[i].MountPoint == path {
+			mountInfo = &infos[i]
+		}
+	}
+	return mountInfo, nil
+}
+
+// getPublishedMountMismatches compares the mount on the target path of NodePublishVolume with the request
+// and returns the attributes which do not match. The target of a filesystem volume is a bind mount of its
+// staging path, so it has the device and root of the staging mount, while the target of a block volume is
+// a bind mount of the device file whose root is the device name.
+func (csiNS *CSINodeServer) getPublishedMountMismatches(ctx context.Context, ctxLogger *zap.Logger, req *csi.NodePublishVolumeRequest) ([]string, error) {
+	target := req.GetTargetPath()
+	targetInfo, err := getMountInfo(target)
+	if err != nil {
+		return nil, err
+	}
+	if targetInfo == nil {
+		return nil, fmt.Errorf("mount of '%s' not found in %s", target, procMountInfoPath)
+	}
+	fileInfo, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	ctxLogger.Info("Target path is already mounted", zap.Reflect("MountInfo", targetInfo))
+
+	var mismatches []string
+	isBlock := req.GetVolumeCapability().GetBlock() != nil
+	requestedAccessType, mountedAccessType := "mount", "mount"
+	if isBlock {
+		requestedAccessType = "block"
+	}
+	if !fileInfo.IsDir() {
+		mountedAccessType = "block"
+	}
+	if requestedAccessType != mountedAccessType {
+		mismatches = append(mismatches, fmt.Sprintf("access type(requested '%s', mounted '%s')", requestedAccessType, mountedAccessType))
+	}
+
+	mountedReadOnly := slices.Contains(targetInfo.MountOptions, "ro")
+	if req.GetReadonly() != mountedReadOnly {
+		mismatches = append(mismatches, fmt.Sprintf("readonly(requested '%t', mounted '%t')", req.GetReadonly(), mountedReadOnly))
+	}
+
+	if isBlock {
+		device, err := csiNS.findDevicePathSource(ctx, ctxLogger, req.GetPublishContext()[PublishInfoDevicePath], getDeviceID(req.GetPublishContext(), req.GetVolumeId()))
+		if err != nil {
+			return nil, err
+		}
+		if realDevice, err := filepath.EvalSymlinks(device); err == nil {
+			device = realDevice
+		}
+		if filepath.Base(device) != filepath.Base(targetInfo.Root) {
+			mismatches = append(mismatches, fmt.Sprintf("device(requested '%s', mounted '%s')", device, targetInfo.Root))
+		}
+		return mismatches, nil
+	}
+
+	stagingTargetPath := req.GetStagingTargetPath()
+	stagingInfo, err := getMountInfo(stagingTargetPath)
+	if err != nil {
+		return nil, err
+	}
+	if stagingInfo == nil {
+		mismatches = append(mismatches, fmt.Sprintf("staging path('%s' is not mounted)", stagingTargetPath))
+		return mismatches, nil
+	}
+	requestedSource := fmt.Sprintf("%d:%d%s", stagingInfo.Major, stagingInfo.Minor, stagingInfo.Root)
+	mountedSource := fmt.Sprintf("%d:%d%s", targetInfo.Major, targetInfo.Minor, targetInfo.Root)
+	if requestedSource != mountedSource {
+		mismatches = append(mismatches, fmt.Sprintf("source(requested '%s' of '%s', mounted '%s')", requestedSource, stagingTargetPath, mountedSource))
+	}
+	return mismatches, nil
 }
@@ -17,30 +17,61 @@ limitations under the License.
 package ibmcsidriver
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	cloudProvider "github.com/IBM/ibmcloud-volume-vpc/pkg/ibmcloudprovider"
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestFindDevicePathSource(t *testing.T) {
 	testCases := []struct {
-		name        string
-		req         string
-		expResponse string
-		expError    error
+		name            string
+		devicePath      string
+		deviceID        string
+		attachOnTrigger bool
+		expResponse     string
+		expErrorOk      bool
+		expTriggerCount int
 	}{
 		{
-			name:        "Valid device path",
-			req:         "/tmp",
-			expResponse: "/tmp",
-			expError:    nil,
+			name:        "Device of the volume found",
+			devicePath:  "/dev/vdd",
+			deviceID:    "0717-volume",
+			expResponse: "/dev/disk/by-id/virtio-0717-volume",
 		},
 		{
-			name:        "nvme device path",
-			req:         "tmp1234422344",
-			expResponse: "tmp1234422344",
-			expError:    nil,
+			name:        "Device of the volume not found but device path exists",
+			devicePath:  "fake",
+			deviceID:    "0717-notattached",
+			expResponse: "fake",
+		},
+		{
+			name:            "Device of the volume found after udev trigger",
+			devicePath:      "/dev/vdd",
+			deviceID:        "0717-notattached",
+			attachOnTrigger: true,
+			expResponse:     "/dev/disk/by-id/virtio-0717-notattached",
+			expTriggerCount: 1,
+		},
+		{
+			name:            "Device of the volume not found till the deadline",
+			devicePath:      "/dev/vdd",
+			deviceID:        "0717-notattached",
+			expErrorOk:      true,
+			expTriggerCount: 1,
+		},
+		{
+			name:            "Error in finding device",
+			devicePath:      "/dev/vdd",
+			deviceID:        "0717-errordevice",
+			expErrorOk:      true,
+			expTriggerCount: 1,
 		},
 	}
 
@@ -50,13 +81,38 @@ func TestFindDevicePathSource(t *testing.T) {
 
 	icDriver := initIBMCSIDriver(t)
 	for _, tc := range testCases {
-		t.Logf("Test case: %s", tc.name)
-		response, err := icDriver.ns.findDevicePathSource(logger, tc.req, "")
-		if tc.expError != nil {
-			assert.Equal(t, tc.expError, err)
-		}
-		assert.Equal(t, tc.expResponse, response)
+		t.Run(tc.name, func(t *testing.T) {
+			deviceUtil := &MockDeviceUtils{attachOnTrigger: tc.attachOnTrigger}
+			icDriver.ns.Devices = deviceUtil
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			response, err := icDriver.ns.findDevicePathSource(ctx, logger, tc.devicePath, tc.deviceID)
+			if tc.expErrorOk {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.expResponse, response)
+			assert.Equal(t, tc.expTriggerCount, deviceUtil.triggerCount)
+		})
 	}
+
+	// Device of the attachment is used over the device path of the publish context
+	deviceID := "0717-2b8c6d4e-3f1a-4b2c-9d8e-7f6a5b4c3d2e-8xvsr"[:20]
+	resolver := newFakeDeviceResolver(t, map[string]map[string]string{"nvme1n1": {"device/serial": deviceID}}, nil)
+	icDriver.ns.Devices = resolver
+	response, err := icDriver.ns.findDevicePathSource(context.Background(), logger, "/dev/vdd", deviceID)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(resolver.devPath, "nvme1n1"), response)
+}
+
+func TestGetDeviceID(t *testing.T) {
+	volumeID := "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	assert.Equal(t, "0717-2b8c6d4e-3f1a-4", getDeviceID(map[string]string{PublishInfoDeviceID: "0717-2b8c6d4e-3f1a-4",
+		PublishInfoDevicePath: "/dev/disk/by-id/virtio-0717-2b8c6d4e-3f1a-4"}, volumeID))
+	// Publish context of the controller not returning the device ID
+	assert.Equal(t, "0717-2b8c6d4e-3f1a-4", getDeviceID(map[string]string{PublishInfoDevicePath: "/dev/disk/by-id/virtio-0717-2b8c6d4e-3f1a-4"}, volumeID))
+	assert.Equal(t, volumeID, getDeviceID(map[string]string{PublishInfoDevicePath: "/dev/vdd"}, volumeID))
 }
 
 func TestProcessMount(t *testing.T) {
@@ -70,23 +126,130 @@ func TestProcessMount(t *testing.T) {
 	t.Logf("Response %v, error %v", response, err)
 }
 
-func TestUdevadmTrigger(t *testing.T) {
+func TestProcessMountForBlock(t *testing.T) {
 	// Creating test logger
 	logger, teardown := cloudProvider.GetTestLogger(t)
 	defer teardown()
 
 	icDriver := initIBMCSIDriver(t)
-	err := icDriver.ns.udevadmTrigger(logger)
-	t.Logf("Response error %v", err)
+	ops := []string{"bind"}
+	response, err := icDriver.ns.processMountForBlock(context.Background(), logger, "ProcessMountForBlock", "/dev/sda", "0717-deviceidxxx", "/targetpath", "volumeidxxx", ops)
+	t.Logf("Response %v, error %v", response, err)
 }
 
-func TestProcessMountForBlock(t *testing.T) {
+// writeFakeMountInfo points the mountinfo of the node to a file with the given mounts
+func writeFakeMountInfo(t *testing.T, mounts ...string) {
+	path := filepath.Join(t.TempDir(), "mountinfo")
+	assert.Nil(t, os.WriteFile(path, []byte(strings.Join(mounts, "\n")+"\n"), 0600))
+	mountInfoPath := procMountInfoPath
+	procMountInfoPath = path
+	t.Cleanup(func() { procMountInfoPath = mountInfoPath })
+}
+
+func TestGetPublishedMountMismatches(t *testing.T) {
+	volumeID := "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	root := t.TempDir()
+	stagingPath := filepath.Join(root, "staging")
+	targetDir := filepath.Join(root, "target")
+	targetFile := filepath.Join(root, "target-block")
+	assert.Nil(t, os.Mkdir(stagingPath, 0750))
+	assert.Nil(t, os.Mkdir(targetDir, 0750))
+	assert.Nil(t, os.WriteFile(targetFile, nil, 0600))
+	stagingMount := "100 25 252:16 / " + stagingPath + " rw,relatime shared:1 - ext4 /dev/vdd rw"
+
 	// Creating test logger
 	logger, teardown := cloudProvider.GetTestLogger(t)
 	defer teardown()
 
 	icDriver := initIBMCSIDriver(t)
-	ops := []string{"bind"}
-	response, err := icDriver.ns.processMountForBlock(logger, "ProcessMountForBlock", "/dev/sda", "/targetpath", "volumeidxxx", ops)
-	t.Logf("Response %v, error %v", response, err)
+	resolver := newFakeDeviceResolver(t, map[string]map[string]string{"vdd": {"serial": volumeID[:20]}}, nil)
+	icDriver.ns.Devices = resolver
+	devicePath := filepath.Join(resolver.devPath, "vdd")
+
+	testCases := []struct {
+		name          string
+		mounts        []string
+		target        string
+		readOnly      bool
+		block         bool
+		expMismatches []string
+		expErrorOk    bool
+	}{
+		{
+			name:   "Bind mount of the staging path",
+			mounts: []string{stagingMount, "101 25 252:16 / " + targetDir + " rw,relatime shared:1 - ext4 /dev/vdd rw"},
+			target: targetDir,
+		},
+		{
+			name:          "Bind mount of the staging path with other readonly",
+			mounts:        []string{stagingMount, "101 25 252:16 / " + targetDir + " rw,relatime shared:1 - ext4 /dev/vdd rw"},
+			target:        targetDir,
+			readOnly:      true,
+			expMismatches: []string{"readonly(requested 'true', mounted 'false')"},
+		},
+		{
+			name:   "Stale bind mount of other volume",
+			mounts: []string{stagingMount, "101 25 252:32 / " + targetDir + " ro,relatime shared:1 - ext4 /dev/vde rw"},
+			target: targetDir,
+			expMismatches: []string{"readonly(requested 'false', mounted 'true')",
+				"source(requested '252:16/' of '" + stagingPath + "', mounted '252:32/')"},
+		},
+		{
+			name:          "Staging path not mounted",
+			mounts:        []string{"101 25 252:16 / " + targetDir + " rw,relatime shared:1 - ext4 /dev/vdd rw"},
+			target:        targetDir,
+			expMismatches: []string{"staging path('" + stagingPath + "' is not mounted)"},
+		},
+		{
+			name:   "Bind mount of the device file",
+			mounts: []string{"101 25 0:5 /vdd " + targetFile + " rw,nosuid shared:2 - devtmpfs devtmpfs rw"},
+			target: targetFile,
+			block:  true,
+		},
+		{
+			name:          "Bind mount of other device file",
+			mounts:        []string{"101 25 0:5 /vde " + targetFile + " rw,nosuid shared:2 - devtmpfs devtmpfs rw"},
+			target:        targetFile,
+			block:         true,
+			expMismatches: []string{"device(requested '" + devicePath + "', mounted '/vde')"},
+		},
+		{
+			name:          "Filesystem mount for block request",
+			mounts:        []string{stagingMount, "101 25 252:16 / " + targetDir + " rw,relatime shared:1 - ext4 /dev/vdd rw"},
+			target:        targetDir,
+			block:         true,
+			expMismatches: []string{"access type(requested 'block', mounted 'mount')", "device(requested '" + devicePath + "', mounted '/')"},
+		},
+		{
+			name:       "Target path not in mountinfo",
+			mounts:     []string{stagingMount},
+			target:     targetDir,
+			expErrorOk: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writeFakeMountInfo(t, tc.mounts...)
+			volumeCapability := stdVolCap[0]
+			if tc.block {
+				volumeCapability = stdBlockVolCap[0]
+			}
+			req := &csi.NodePublishVolumeRequest{
+				VolumeId:          volumeID,
+				PublishContext:    map[string]string{PublishInfoDevicePath: "/dev/vdd"},
+				StagingTargetPath: stagingPath,
+				TargetPath:        tc.target,
+				VolumeCapability:  volumeCapability,
+				Readonly:          tc.readOnly,
+			}
+			mismatches, err := icDriver.ns.getPublishedMountMismatches(context.Background(), logger, req)
+			if tc.expErrorOk {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expMismatches, mismatches)
+		})
+	}
 }
@@ -24,10 +24,13 @@ import (
 	"strings"
 	"testing"
 
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/exec"
 	testingexec "k8s.io/utils/exec/testing"
 
 	"github.com/IBM/ibm-csi-common/pkg/utils"
+	cloudProvider "github.com/IBM/ibmcloud-volume-vpc/pkg/ibmcloudprovider"
 	csi "github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/context"
@@ -72,6 +75,34 @@ func (su *MockStatUtils) IsDevicePathNotExist(devicePath string) bool {
 	return strings.Contains(devicePath, "correctdevicepath")
 }
 
+type MockDeviceUtils struct {
+	triggerCount    int
+	attachOnTrigger bool
+}
+
+func (du *MockDeviceUtils) FindDevice(deviceID string) (string, error) {
+	if strings.Contains(deviceID, "errordevice") {
+		return "", errors.New("error in finding device")
+	} else if strings.Contains(deviceID, "notattached") && (!du.attachOnTrigger || du.triggerCount == 0) {
+		return "", nil
+	}
+	return "/dev/disk/by-id/virtio-" + deviceID, nil
+}
+
+func (du *MockDeviceUtils) VerifyDevice(devicePath string, deviceID string) (bool, error) {
+	if strings.Contains(deviceID, "wrongdevice") {
+		return false, errors.New("serial of the device is not of the volume")
+	} else if strings.Contains(deviceID, "noserial") {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (du *MockDeviceUtils) TriggerUdev(ctx context.Context) error {
+	du.triggerCount++
+	return nil
+}
+
 func TestNodePublishVolume(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -203,6 +234,30 @@ func TestNodePublishVolume(t *testing.T) {
 	}
 }
 
+func TestNodePublishVolumeAlreadyMounted(t *testing.T) {
+	icDriver := initIBMCSIDriver(t)
+	targetPath := t.TempDir()
+	assert.Nil(t, icDriver.ns.Mounter.Mount(defaultStagingPath, targetPath, "ext4", []string{"bind"}))
+	writeFakeMountInfo(t,
+		"100 25 252:16 / "+defaultStagingPath+" rw,relatime shared:1 - ext4 /dev/vdd rw",
+		"101 25 252:16 / "+targetPath+" rw,relatime shared:1 - ext4 /dev/vdd rw")
+	req := &csi.NodePublishVolumeRequest{
+		VolumeId:          defaultVolumeID,
+		TargetPath:        targetPath,
+		StagingTargetPath: defaultStagingPath,
+		VolumeCapability:  stdVolCap[0],
+	}
+
+	// Target path is the mount of the volume as requested
+	_, err := icDriver.ns.NodePublishVolume(context.Background(), req)
+	assert.Nil(t, err)
+
+	// Target path is mounted read-write but readonly is requested
+	req.Readonly = true
+	_, err = icDriver.ns.NodePublishVolume(context.Background(), req)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
 func TestNodeUnpublishVolume(t *testing.T) {
 	testCases := []struct {
 		name       string
@@ -408,6 +463,89 @@ func TestNodeStageVolume(t *testing.T) {
 	}
 }
 
+func TestNodeStageVolumeDeviceChecks(t *testing.T) {
+	testCases := []struct {
+		name           string
+		volumeID       string
+		publishContext map[string]string
+		volumeContext  map[string]string
+		expErrCode     codes.Code
+		expEvent       string
+	}{
+		{
+			name:           "Device of other volume",
+			volumeID:       defaultVolumeID,
+			publishContext: map[string]string{PublishInfoDevicePath: "/dev/vdd", PublishInfoDeviceID: "0717-wrongdevice"},
+			expErrCode:     codes.FailedPrecondition,
+			expEvent:       DeviceIdentityMismatch,
+		},
+		{
+			name:           "Device without readable serial verified by its link",
+			volumeID:       defaultVolumeID,
+			publishContext: map[string]string{PublishInfoDevicePath: "/dev/vdd", PublishInfoDeviceID: "0717-noserial"},
+			expErrCode:     codes.OK,
+			expEvent:       DeviceIdentityUnverified,
+		},
+		{
+			name:           "Volume restored from snapshot without filesystem",
+			volumeID:       defaultVolumeID,
+			publishContext: map[string]string{PublishInfoDevicePath: "/dev/vdd", PublishInfoSourceSnapshotID: "snapshot-id"},
+			expErrCode:     codes.DataLoss,
+			expEvent:       SnapshotRestoreWithoutFilesystem,
+		},
+		{
+			name:           "Volume restored from snapshot without filesystem allowed to be formatted",
+			volumeID:       defaultVolumeID,
+			publishContext: map[string]string{PublishInfoDevicePath: "/dev/vdd", PublishInfoSourceSnapshotID: "snapshot-id"},
+			volumeContext:  map[string]string{FormatRestoredVolume: TrueStr},
+			expErrCode:     codes.OK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// blkid finds no filesystem
+			icDriver := initIBMCSIDriver(t)
+			recorder := record.NewFakeRecorder(1)
+			icDriver.SetEventRecorder(recorder, &v1.ObjectReference{Kind: "Node", Name: "testnode", UID: "3f6c1d2a-8b4e-4c7f-9a1d-2e5b6c7d8e9f"})
+			_, err := icDriver.ns.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+				VolumeId:          tc.volumeID,
+				StagingTargetPath: defaultStagingPath,
+				VolumeCapability:  stdVolCap[0],
+				PublishContext:    tc.publishContext,
+				VolumeContext:     tc.volumeContext,
+			})
+			assert.Equal(t, tc.expErrCode, status.Code(err))
+			if len(tc.expEvent) == 0 {
+				assert.Empty(t, recorder.Events)
+				return
+			}
+			assert.Len(t, recorder.Events, 1)
+			assert.Contains(t, <-recorder.Events, "Warning "+tc.expEvent)
+		})
+	}
+}
+
+func TestCheckRestoredFilesystem(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	icDriver := initIBMCSIDriver(t, makeFakeCmd(
+		&testingexec.FakeCmd{
+			CombinedOutputScript: []testingexec.FakeAction{
+				func() ([]byte, []byte, error) {
+					return []byte("DEVNAME=/dev/vdd\nTYPE=ext4"), nil, nil
+				},
+			},
+		},
+		"blkid",
+	))
+	// Volume restored from snapshot with filesystem
+	assert.Nil(t, icDriver.ns.checkRestoredFilesystem(logger, "/dev/vdd", defaultVolumeID, "snapshot-id"))
+	// Volume not restored from snapshot is not checked
+	assert.Nil(t, icDriver.ns.checkRestoredFilesystem(logger, "/dev/vdd", defaultVolumeID, ""))
+}
+
 func TestNodeUnstageVolume(t *testing.T) {
 	testCases := []struct {
 		name       string
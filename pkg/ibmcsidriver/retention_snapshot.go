@@ -0,0 +1,169 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ibmcsidriver ...
+package ibmcsidriver
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	vpcprovider "github.com/IBM/ibmcloud-volume-vpc/block/provider"
+	"github.com/IBM/ibmcloud-volume-vpc/common/vpcclient/models"
+	"github.com/IBM/ibmcloud-volume-vpc/common/vpcclient/vpcvolume"
+	iksprovider "github.com/IBM/ibmcloud-volume-vpc/iks/provider"
+	"go.uber.org/zap"
+)
+
+// retentionSnapshotSweepInterval is the interval between two sweeps of the expired retention snapshots
+var retentionSnapshotSweepInterval = 1 * time.Hour
+
+// getSnapshotService returns the VPC snapshot service of the session, nil if the session is not of VPC. Snapshots
+// listed by the provider session have no names, the sweeper lists them from the service to match their names.
+var getSnapshotService = func(session provider.Session) vpcvolume.SnapshotManager {
+	var vpcSession *vpcprovider.VPCSession
+	switch s := session.(type) {
+	case *vpcprovider.VPCSession:
+		vpcSession = s
+	case *iksprovider.IksVpcSession:
+		vpcSession = &s.VPCSession
+	}
+	if vpcSession == nil || vpcSession.Apiclient == nil {
+		return nil
+	}
+	return vpcSession.Apiclient.SnapshotService()
+}
+
+// getRetentionSnapshotName returns the name of the snapshot taken of the volume on delete. Provider does not
+// tag the snapshots, so the name is derived from the cluster and the volume for the snapshot to be found by
+// the sweeper and by the user restoring the volume. The cluster ID is hashed to fit the 63 chars of the name.
+func getRetentionSnapshotName(clusterID string, volumeID string) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(clusterID))
+	return fmt.Sprintf("%s%08x-%s", RetentionSnapshotPrefix, hash.Sum32(), volumeID)
+}
+
+// createRetentionSnapshot takes the snapshot of the volume which is being deleted and waits till it is ready
+// to use. The snapshot is named by getRetentionSnapshotName as the provider creates it untagged.
+func createRetentionSnapshot(ctx context.Context, logger *zap.Logger, session provider.Session, clusterID string, volume provider.Volume) (*provider.Snapshot, error) {
+	snapshotName := getRetentionSnapshotName(clusterID, volume.VolumeID)
+	snapshotParameters := provider.SnapshotParameters{
+		Name:         snapshotName,
+		SnapshotTags: provider.SnapshotTags{"name": snapshotName},
+	}
+	return createSnapshotAndWait(ctx, logger, session, volume.VolumeID, snapshotParameters)
+}
+
+// sweepRetentionSnapshots deletes the retention snapshots of the cluster which are older than the retention
+// period. It returns the number of snapshots deleted. VPC filters the snapshots by the exact name only, so the
+// snapshots of the resource group of the driver, where the retention snapshots are created, are listed and
+// filtered by the retention name prefix of the cluster.
+func (csiCS *CSIControllerServer) sweepRetentionSnapshots(ctx context.Context, logger *zap.Logger, retention time.Duration) (int, error) {
+	clusterID := csiCS.CSIProvider.GetClusterID()
+	// Retention names of all the clusters without ID are the same, the snapshots of other clusters would be deleted
+	if len(clusterID) == 0 {
+		return 0, fmt.Errorf("retention snapshots are not swept as the cluster ID is not known")
+	}
+	session, err := csiCS.CSIProvider.GetProviderSession(ctx, logger)
+	if err != nil {
+		return 0, err
+	}
+	snapshotService := getSnapshotService(session)
+	if snapshotService == nil {
+		return 0, fmt.Errorf("retention snapshots are not swept as the provider session can not list the snapshot names")
+	}
+	filters := &models.LisSnapshotFilters{}
+	if config := csiCS.CSIProvider.GetConfig(); config != nil && config.VPC != nil {
+		filters.ResourceGroupID = config.VPC.G2ResourceGroupID
+	}
+	namePrefix := getRetentionSnapshotName(clusterID, "")
+	expiry := time.Now().Add(-retention)
+	deleted := 0
+	start := ""
+	for {
+		snapshotList, err := snapshotService.ListSnapshots(ListSnapshotsMaxLimit, start, filters, logger)
+		if err != nil {
+			return deleted, err
+		}
+		if snapshotList == nil {
+			break
+		}
+		for _, snapshot := range snapshotList.Snapshots {
+			if snapshot == nil || !strings.HasPrefix(snapshot.Name, namePrefix) || snapshot.CreatedAt == nil || snapshot.CreatedAt.After(expiry) {
+				continue
+			}
+			if err = session.DeleteSnapshot(&provider.Snapshot{SnapshotID: snapshot.ID}); err != nil {
+				logger.Warn("Failed to delete expired retention snapshot", zap.String("SnapshotName", snapshot.Name), zap.String("SnapshotID", snapshot.ID), zap.Error(err))
+				continue
+			}
+			logger.Info("Expired retention snapshot deleted", zap.String("SnapshotName", snapshot.Name), zap.String("SnapshotID", snapshot.ID),
+				zap.Time("CreationTime", *snapshot.CreatedAt))
+			deleted++
+		}
+		start = getSnapshotListStart(snapshotList.Next)
+		if len(start) == 0 {
+			break
+		}
+	}
+	return deleted, nil
+}
+
+// getSnapshotListStart returns the start of the next page of the snapshot list from its href
+// e.g https://us-south.iaas.cloud.ibm.com/v1/snapshots?start=<snapshot-id>&limit=100, empty if there is none
+func getSnapshotListStart(next *models.HReference) string {
+	if next == nil {
+		return ""
+	}
+	nextURL, err := url.Parse(next.Href)
+	if err != nil {
+		return ""
+	}
+	return nextURL.Query().Get("start")
+}
+
+// StartRetentionSnapshotSweeper deletes the retention snapshots older than the retention period, once every
+// sweep interval till the context is done. Retention snapshots are kept forever if the retention is not positive.
+func (icDriver *IBMCSIDriver) StartRetentionSnapshotSweeper(ctx context.Context, retention time.Duration) {
+	if retention <= 0 {
+		icDriver.logger.Info("Retention snapshots are not swept as the retention period is not set")
+		return
+	}
+	if len(icDriver.cs.CSIProvider.GetClusterID()) == 0 {
+		icDriver.logger.Warn("Retention snapshots are not swept as the cluster ID is not known")
+		return
+	}
+	icDriver.logger.Info("Starting retention snapshot sweeper", zap.Duration("Retention", retention), zap.Duration("Interval", retentionSnapshotSweepInterval))
+	go func() {
+		ticker := time.NewTicker(retentionSnapshotSweepInterval)
+		defer ticker.Stop()
+		for {
+			deleted, err := icDriver.cs.sweepRetentionSnapshots(ctx, icDriver.logger, retention)
+			if err != nil {
+				icDriver.logger.Warn("Failed to sweep retention snapshots", zap.Int("Deleted", deleted), zap.Error(err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ibmcsidriver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider/fake"
+	"github.com/IBM/ibmcloud-volume-vpc/common/vpcclient/models"
+	"github.com/IBM/ibmcloud-volume-vpc/common/vpcclient/vpcvolume"
+	cloudProvider "github.com/IBM/ibmcloud-volume-vpc/pkg/ibmcloudprovider"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeSnapshotService returns the pages of the snapshot list in order and records the list calls
+type fakeSnapshotService struct {
+	vpcvolume.SnapshotManager
+	pages   []*models.SnapshotList
+	starts  []string
+	filters []*models.LisSnapshotFilters
+}
+
+func (f *fakeSnapshotService) ListSnapshots(_ int, start string, filters *models.LisSnapshotFilters, _ *zap.Logger) (*models.SnapshotList, error) {
+	f.starts = append(f.starts, start)
+	f.filters = append(f.filters, filters)
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+	return page, nil
+}
+
+func TestGetRetentionSnapshotName(t *testing.T) {
+	volumeID := "r006-7b3c4f2e-1a2b-4c3d-8e9f-0a1b2c3d4e5f"
+	name := getRetentionSnapshotName("fake-clusterID", volumeID)
+	assert.LessOrEqual(t, len(name), 63)
+	assert.Equal(t, name, getRetentionSnapshotName("fake-clusterID", volumeID))
+	assert.NotEqual(t, name, getRetentionSnapshotName("other-clusterID", volumeID))
+}
+
+func TestCreateRetentionSnapshot(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	session := &fake.FakeSession{}
+	session.CreateSnapshotReturns(&provider.Snapshot{SnapshotID: "snap-1", VolumeID: "vol-1", ReadyToUse: true}, nil)
+	volume := provider.Volume{VolumeID: "vol-1"}
+
+	snapshot, err := createRetentionSnapshot(context.Background(), logger, session, "fake-clusterID", volume)
+	assert.Nil(t, err)
+	assert.Equal(t, "snap-1", snapshot.SnapshotID)
+	sourceVolumeID, snapshotParameters := session.CreateSnapshotArgsForCall(0)
+	assert.Equal(t, "vol-1", sourceVolumeID)
+	assert.Equal(t, getRetentionSnapshotName("fake-clusterID", "vol-1"), snapshotParameters.Name)
+
+	// Snapshot of the previous attempt is reused
+	session.GetSnapshotByNameReturns(&provider.Snapshot{SnapshotID: "snap-1", VolumeID: "vol-1", ReadyToUse: true}, nil)
+	_, err = createRetentionSnapshot(context.Background(), logger, session, "fake-clusterID", volume)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, session.CreateSnapshotCallCount())
+}
+
+func TestSweepRetentionSnapshots(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	icDriver := initIBMCSIDriver(t)
+	fakeSession, err := icDriver.cs.CSIProvider.GetProviderSession(context.Background(), logger)
+	assert.Nil(t, err)
+	session := fakeSession.(*fake.FakeSession)
+
+	old := time.Now().Add(-48 * time.Hour)
+	now := time.Now()
+	snapshotService := &fakeSnapshotService{pages: []*models.SnapshotList{
+		{Next: &models.HReference{Href: "https://testregion.iaas.cloud.ibm.com/v1/snapshots?start=r006-next&limit=100"}, Snapshots: []*models.Snapshot{
+			// Expired retention snapshot
+			{ID: "snap-1", Name: getRetentionSnapshotName("fake-clusterID", "vol-1"), CreatedAt: &old},
+			// Retention snapshot of other cluster
+			{ID: "snap-2", Name: getRetentionSnapshotName("other-clusterID", "vol-2"), CreatedAt: &old},
+		}},
+		{Snapshots: []*models.Snapshot{
+			// Retention snapshot not expired yet
+			{ID: "snap-3", Name: getRetentionSnapshotName("fake-clusterID", "vol-3"), CreatedAt: &now},
+			// Snapshot taken by the user
+			{ID: "snap-4", Name: "backup-vol-1", CreatedAt: &old},
+		}},
+	}}
+	getSnapshotServiceOrig := getSnapshotService
+	defer func() { getSnapshotService = getSnapshotServiceOrig }()
+	getSnapshotService = func(provider.Session) vpcvolume.SnapshotManager {
+		return snapshotService
+	}
+
+	icDriver.cs.CSIProvider.GetConfig().VPC.G2ResourceGroupID = "rg-1"
+
+	deleted, err := icDriver.cs.sweepRetentionSnapshots(context.Background(), logger, 24*time.Hour)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, []string{"", "r006-next"}, snapshotService.starts)
+	assert.Equal(t, &models.LisSnapshotFilters{ResourceGroupID: "rg-1"}, snapshotService.filters[0])
+	// Retention snapshots are matched by the name prefix of the cluster, not looked up by the name
+	assert.Equal(t, 0, session.GetSnapshotByNameCallCount())
+	assert.Equal(t, 1, session.DeleteSnapshotCallCount())
+	assert.Equal(t, "snap-1", session.DeleteSnapshotArgsForCall(0).SnapshotID)
+
+	// Retention names are the same for all the clusters without ID
+	icDriver.cs.CSIProvider.(*cloudProvider.FakeIBMCloudStorageProvider).ClusterID = ""
+	deleted, err = icDriver.cs.sweepRetentionSnapshots(context.Background(), logger, 24*time.Hour)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Equal(t, 2, len(snapshotService.starts))
+}
+
+func TestSweepRetentionSnapshotsWithoutSnapshotService(t *testing.T) {
+	logger, teardown := cloudProvider.GetTestLogger(t)
+	defer teardown()
+
+	// Fake session is not of VPC, snapshot names can not be listed
+	icDriver := initIBMCSIDriver(t)
+	deleted, err := icDriver.cs.sweepRetentionSnapshots(context.Background(), logger, 24*time.Hour)
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
+func TestGetSnapshotListStart(t *testing.T) {
+	assert.Equal(t, "", getSnapshotListStart(nil))
+	assert.Equal(t, "r006-next", getSnapshotListStart(&models.HReference{Href: "https://testregion.iaas.cloud.ibm.com/v1/snapshots?limit=100&start=r006-next"}))
+	assert.Equal(t, "", getSnapshotListStart(&models.HReference{Href: "https://testregion.iaas.cloud.ibm.com/v1/snapshots?limit=100"}))
+}
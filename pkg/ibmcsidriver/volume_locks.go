@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ibmcsidriver ...
+package ibmcsidriver
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// volumeLocks are the volume IDs and target paths with a node operation in progress. Operations
+// are not queued, an operation finding its volume or target path locked fails so that the CO retries.
+type volumeLocks struct {
+	mutex sync.Mutex
+	locks map[string]struct{}
+}
+
+// tryAcquire locks all the keys, or none of them if any is already locked. It returns false if the
+// keys could not be locked.
+func (vl *volumeLocks) tryAcquire(keys ...string) bool {
+	vl.mutex.Lock()
+	defer vl.mutex.Unlock()
+	if vl.locks == nil {
+		vl.locks = make(map[string]struct{})
+	}
+	for _, key := range keys {
+		if _, ok := vl.locks[key]; ok {
+			return false
+		}
+	}
+	for _, key := range keys {
+		vl.locks[key] = struct{}{}
+	}
+	return true
+}
+
+// release unlocks the keys
+func (vl *volumeLocks) release(keys ...string) {
+	vl.mutex.Lock()
+	defer vl.mutex.Unlock()
+	for _, key := range keys {
+		delete(vl.locks, key)
+	}
+}
+
+// lockVolume locks the volume and the target path of a node operation, the returned release function
+// must be called once the operation is done. It fails with Aborted if an operation of the volume or of
+// the target path is pending.
+func (csiNS *CSINodeServer) lockVolume(volumeID string, targetPath string) (func(), error) {
+	if !csiNS.volumeLocks.tryAcquire(volumeID, targetPath) {
+		return nil, status.Errorf(codes.Aborted, "An operation is pending for the volume '%s' or the target path '%s'", volumeID, targetPath)
+	}
+	return func() {
+		csiNS.volumeLocks.release(volumeID, targetPath)
+	}, nil
+}
+
+// formatLimiter bounds the number of volumes formatted at the same time on the node, nil slots
+// mean no limit
+type formatLimiter struct {
+	slots chan struct{}
+}
+
+// newFormatLimiter returns the limiter of maxConcurrent formats, no limit if it is not positive
+func newFormatLimiter(maxConcurrent int) *formatLimiter {
+	if maxConcurrent <= 0 {
+		return &formatLimiter{}
+	}
+	return &formatLimiter{slots: make(chan struct{}, maxConcurrent)}
+}
+
+// acquire waits for a format slot, the returned release function must be called once the format is
+// done. Waiting ends with the context error if the context is done before a slot is free.
+func (fl *formatLimiter) acquire(ctx context.Context) (func(), error) {
+	if fl == nil || fl.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case fl.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-fl.slots
+		})
+	}, nil
+}
+
+// SetMaxConcurrentFormat sets the number of volumes formatted at the same time on the node, there is
+// no limit if it is not positive
+func (icDriver *IBMCSIDriver) SetMaxConcurrentFormat(maxConcurrent int) {
+	icDriver.ns.formatLimiter = newFormatLimiter(maxConcurrent)
+}
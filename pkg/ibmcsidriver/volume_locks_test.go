@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ibmcsidriver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	csi "github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestVolumeLocks(t *testing.T) {
+	locks := volumeLocks{}
+	assert.True(t, locks.tryAcquire("vol-1", "/target-1"))
+	// Volume or target path already locked
+	assert.False(t, locks.tryAcquire("vol-1", "/target-2"))
+	assert.False(t, locks.tryAcquire("vol-2", "/target-1"))
+	// None of the keys is locked if any is already locked
+	assert.True(t, locks.tryAcquire("vol-2", "/target-2"))
+	locks.release("vol-2", "/target-2")
+
+	locks.release("vol-1", "/target-1")
+	assert.True(t, locks.tryAcquire("vol-1", "/target-2"))
+}
+
+func TestNodeOperationPending(t *testing.T) {
+	icDriver := initIBMCSIDriver(t)
+	release, err := icDriver.ns.lockVolume(defaultVolumeID, defaultStagingPath)
+	assert.Nil(t, err)
+
+	// Operations of the volume are rejected while another one is pending
+	_, err = icDriver.ns.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+		VolumeId:          defaultVolumeID,
+		StagingTargetPath: defaultStagingPath,
+		VolumeCapability:  stdVolCap[0],
+		PublishContext:    map[string]string{PublishInfoDevicePath: "/dev/vdd"},
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	_, err = icDriver.ns.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+		VolumeId:          defaultVolumeID,
+		TargetPath:        defaultTargetPath,
+		StagingTargetPath: defaultStagingPath,
+		VolumeCapability:  stdVolCap[0],
+	})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	// Operations of other volumes go ahead
+	_, err = icDriver.ns.NodeUnpublishVolume(context.Background(), &csi.NodeUnpublishVolumeRequest{
+		VolumeId:   "other-volume",
+		TargetPath: defaultTargetPath,
+	})
+	assert.NotEqual(t, codes.Aborted, status.Code(err))
+
+	release()
+	_, err = icDriver.ns.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+		VolumeId:          defaultVolumeID,
+		StagingTargetPath: defaultStagingPath,
+	})
+	assert.NotEqual(t, codes.Aborted, status.Code(err))
+}
+
+func TestFormatLimiter(t *testing.T) {
+	limiter := newFormatLimiter(1)
+	release, err := limiter.acquire(context.Background())
+	assert.Nil(t, err)
+
+	// Waiting for the slot ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = limiter.acquire(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	release()
+	// Release is called once only
+	release()
+	release, err = limiter.acquire(context.Background())
+	assert.Nil(t, err)
+	release()
+
+	// No limit
+	limiter = newFormatLimiter(0)
+	for i := 0; i < 3; i++ {
+		_, err = limiter.acquire(context.Background())
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ibmcsidriver ...
+package ibmcsidriver
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+)
+
+// profileCapability is what a volume profile supports, the IOPS of the tiered profiles is derived
+// from the tier hence they have no IOPS bands, and only the profiles with throughput bounds accept
+// a throughput. See https://cloud.ibm.com/docs/vpc?topic=vpc-block-storage-profiles
+type profileCapability struct {
+	minCapacityBytes int64
+	maxCapacityBytes int64
+	iopsBands        []iopsBand
+	minThroughput    int32 // in Mbps
+	maxThroughput    int32 // in Mbps
+}
+
+// iopsBand is the IOPS range supported for the volumes with size(in GiB) up to maxCapacity
+type iopsBand struct {
+	maxCapacity int
+	minIops     int64
+	maxIops     int64
+}
+
+// profileCapabilities the capabilities of the supported profiles
+var profileCapabilities = map[string]profileCapability{
+	GeneralPurposeProfile: {minCapacityBytes: utils.MinimumVolumeSizeInBytes, maxCapacityBytes: MaximumVolumeSizeInBytes},
+	FiveIopsTierProfile:   {minCapacityBytes: utils.MinimumVolumeSizeInBytes, maxCapacityBytes: MaximumVolumeSizeInBytes},
+	TenIopsTierProfile:    {minCapacityBytes: utils.MinimumVolumeSizeInBytes, maxCapacityBytes: MaximumVolumeSizeInBytes},
+	CustomProfile: {
+		minCapacityBytes: utils.MinimumVolumeSizeInBytes,
+		maxCapacityBytes: MaximumVolumeSizeInBytes,
+		iopsBands: []iopsBand{
+			{maxCapacity: 39, minIops: 100, maxIops: 1000},
+			{maxCapacity: 79, minIops: 100, maxIops: 2000},
+			{maxCapacity: 99, minIops: 100, maxIops: 4000},
+			{maxCapacity: 499, minIops: 100, maxIops: 6000},
+			{maxCapacity: 999, minIops: 100, maxIops: 10000},
+			{maxCapacity: 1999, minIops: 100, maxIops: 20000},
+			{maxCapacity: 3999, minIops: 200, maxIops: 40000},
+			{maxCapacity: 7999, minIops: 300, maxIops: 40000},
+			{maxCapacity: 9999, minIops: 500, maxIops: 48000},
+			{maxCapacity: 16000, minIops: 1000, maxIops: 48000},
+		},
+	},
+	SDPProfile: {
+		minCapacityBytes: MinimumSDPVolumeSizeInBytes,
+		maxCapacityBytes: MaximumSDPVolumeSizeInBytes,
+		iopsBands: []iopsBand{
+			{maxCapacity: 32000, minIops: 3000, maxIops: 64000},
+		},
+		minThroughput: 1000,
+		maxThroughput: 8192,
+	},
+}
+
+// getVolumeSizeLimits returns the minimum and maximum volume size in bytes for the profile
+func getVolumeSizeLimits(profileName string) (int64, int64) {
+	if capability, ok := profileCapabilities[profileName]; ok {
+		return capability.minCapacityBytes, capability.maxCapacityBytes
+	}
+	return utils.MinimumVolumeSizeInBytes, MaximumVolumeSizeInBytes
+}
+
+// validateProfileCapabilities validates the size, IOPS and throughput of the volume to be created
+// against its profile, so that invalid combinations are not sent to VPC
+func validateProfileCapabilities(volume *provider.Volume) error {
+	if volume.Profile == nil || volume.Capacity == nil {
+		return nil
+	}
+	if err := validateCapacity(volume.Profile.Name, *volume.Capacity); err != nil {
+		return err
+	}
+	if err := validateIops(volume.Profile.Name, *volume.Capacity, volume.Iops); err != nil {
+		return err
+	}
+	return validateThroughput(volume.Profile.Name, volume.Bandwidth)
+}
+
+// validateCapacity checks the volume size(in GiB) is in the range supported by the profile
+func validateCapacity(profileName string, capacity int) error {
+	capability, ok := profileCapabilities[profileName]
+	if !ok {
+		return nil
+	}
+	capacityBytes := int64(capacity) * utils.GiB
+	if capacityBytes < capability.minCapacityBytes || capacityBytes > capability.maxCapacityBytes {
+		return fmt.Errorf("volume size %dGiB is out of the supported range %d-%dGiB of '%s' profile", capacity,
+			capability.minCapacityBytes/utils.GiB, capability.maxCapacityBytes/utils.GiB, profileName)
+	}
+	return nil
+}
+
+// validateIops checks the IOPS is in the range supported by the profile for the volume size(in GiB),
+// the whole range of the profile is allowed if the size is not known. Profiles which do not take
+// IOPS are not checked as their IOPS is dropped before calling VPC
+func validateIops(profileName string, capacity int, iops *string) error {
+	capability, ok := profileCapabilities[profileName]
+	if !ok || len(capability.iopsBands) == 0 || iops == nil || len(*iops) == 0 {
+		return nil
+	}
+	value, err := strconv.ParseInt(*iops, 10, 64)
+	if err != nil {
+		return fmt.Errorf("'<%v>' is invalid, value of '%s' should be an integer", *iops, IOPS)
+	}
+
+	bands := capability.iopsBands
+	minIops, maxIops := bands[0].minIops, bands[len(bands)-1].maxIops
+	for _, band := range bands {
+		minIops = min(minIops, band.minIops)
+		maxIops = max(maxIops, band.maxIops)
+	}
+	sizeInfo := ""
+	if capacity > 0 {
+		for _, band := range bands {
+			if capacity <= band.maxCapacity {
+				minIops, maxIops = band.minIops, band.maxIops
+				break
+			}
+		}
+		sizeInfo = fmt.Sprintf(" for %dGiB volume", capacity)
+	}
+	if value < minIops || value > maxIops {
+		return fmt.Errorf("%s:<%d> is out of the supported range %d-%d%s of '%s' profile", IOPS, value, minIops, maxIops, sizeInfo, profileName)
+	}
+	return nil
+}
+
+// validateThroughput checks the throughput(in Mbps) is in the range supported by the profile,
+// zero means the throughput is not requested
+func validateThroughput(profileName string, throughput int32) error {
+	capability, ok := profileCapabilities[profileName]
+	if !ok || throughput == 0 {
+		return nil
+	}
+	if capability.maxThroughput == 0 {
+		return fmt.Errorf("'%s' can be specified only for '%s' profile", Throughput, SDPProfile)
+	}
+	if throughput < capability.minThroughput || throughput > capability.maxThroughput {
+		return fmt.Errorf("%s:<%d> is out of the supported range %d-%d of '%s' profile", Throughput, throughput, capability.minThroughput, capability.maxThroughput, profileName)
+	}
+	return nil
+}
+
+// validateVolumeExpansion validates the new size(in bytes) of the volume against its profile, the
+// IOPS of the volume has to be in the range of the new size as VPC does not change it on expansion
+func validateVolumeExpansion(volume *provider.Volume, capacityBytes int64) error {
+	if volume.Profile == nil {
+		return nil
+	}
+	capacity := utils.BytesToGiB(utils.RoundUpBytes(capacityBytes))
+	// Volume is not expanded if it is already of the requested size
+	if volume.Capacity != nil && *volume.Capacity >= capacity {
+		return nil
+	}
+	if err := validateCapacity(volume.Profile.Name, capacity); err != nil {
+		return err
+	}
+	return validateIops(volume.Profile.Name, capacity, volume.Iops)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ibmcsidriver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IBM/ibm-csi-common/pkg/utils"
+	"github.com/IBM/ibmcloud-volume-interface/lib/provider"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateProfileCapabilities(t *testing.T) {
+	newVolume := func(profile string, capacity int, iops string, throughput int32) *provider.Volume {
+		volume := &provider.Volume{Capacity: &capacity, Iops: &iops}
+		volume.Profile = &provider.Profile{Name: profile}
+		volume.Bandwidth = throughput
+		return volume
+	}
+	testCases := []struct {
+		testCaseName  string
+		volume        *provider.Volume
+		expectedError error
+	}{
+		{
+			testCaseName: "Tiered volume",
+			volume:       newVolume("10iops-tier", 100, "", 0),
+		},
+		{
+			testCaseName:  "Tiered volume above max size",
+			volume:        newVolume("5iops-tier", 16001, "", 0),
+			expectedError: fmt.Errorf("volume size %dGiB is out of the supported range %d-%dGiB of '%s' profile", 16001, 10, 16000, "5iops-tier"),
+		},
+		{
+			testCaseName: "Custom volume with iops at the band limits",
+			volume:       newVolume(CustomProfile, 2000, "40000", 0),
+		},
+		{
+			testCaseName:  "Custom volume with iops below the band",
+			volume:        newVolume(CustomProfile, 2000, "100", 0),
+			expectedError: fmt.Errorf("%s:<%d> is out of the supported range %d-%d%s of '%s' profile", IOPS, 100, 200, 40000, " for 2000GiB volume", CustomProfile),
+		},
+		{
+			testCaseName:  "Custom volume with invalid iops",
+			volume:        newVolume(CustomProfile, 20, "fast", 0),
+			expectedError: fmt.Errorf("'<%v>' is invalid, value of '%s' should be an integer", "fast", IOPS),
+		},
+		{
+			testCaseName: "Sdp volume of min size with throughput",
+			volume:       newVolume(SDPProfile, 1, "3000", 8192),
+		},
+		{
+			testCaseName:  "Sdp volume with throughput below the range",
+			volume:        newVolume(SDPProfile, 100, "", 500),
+			expectedError: fmt.Errorf("%s:<%d> is out of the supported range %d-%d of '%s' profile", Throughput, 500, 1000, 8192, SDPProfile),
+		},
+		{
+			testCaseName:  "Custom volume with throughput",
+			volume:        newVolume(CustomProfile, 100, "", 1000),
+			expectedError: fmt.Errorf("'%s' can be specified only for '%s' profile", Throughput, SDPProfile),
+		},
+	}
+
+	for _, testcase := range testCases {
+		t.Run(testcase.testCaseName, func(t *testing.T) {
+			assert.Equal(t, testcase.expectedError, validateProfileCapabilities(testcase.volume))
+		})
+	}
+}
+
+func TestValidateVolumeExpansion(t *testing.T) {
+	capacity := 1000
+	iops := "100"
+	volume := &provider.Volume{Capacity: &capacity, Iops: &iops}
+	volume.Profile = &provider.Profile{Name: CustomProfile}
+
+	// Lower size is not an expansion
+	assert.Nil(t, validateVolumeExpansion(volume, 500*utils.GiB))
+	// Iops of the volume has to be in the band of the new size
+	assert.Nil(t, validateVolumeExpansion(volume, 1999*utils.GiB))
+	assert.Equal(t, fmt.Errorf("%s:<%d> is out of the supported range %d-%d%s of '%s' profile", IOPS, 100, 200, 40000, " for 2000GiB volume", CustomProfile),
+		validateVolumeExpansion(volume, 2000*utils.GiB))
+
+	iops = "3000"
+	volume.Profile = &provider.Profile{Name: SDPProfile}
+	assert.Nil(t, validateVolumeExpansion(volume, 32000*utils.GiB))
+	assert.NotNil(t, validateVolumeExpansion(volume, 32001*utils.GiB))
+}
+
+func TestGetVolumeSizeLimits(t *testing.T) {
+	minSize, maxSize := getVolumeSizeLimits(SDPProfile)
+	assert.Equal(t, MinimumSDPVolumeSizeInBytes, minSize)
+	assert.Equal(t, MaximumSDPVolumeSizeInBytes, maxSize)
+
+	// Limits of the tiered profiles are used if profile is not known
+	minSize, maxSize = getVolumeSizeLimits("")
+	assert.Equal(t, utils.MinimumVolumeSizeInBytes, minSize)
+	assert.Equal(t, MaximumVolumeSizeInBytes, maxSize)
+}
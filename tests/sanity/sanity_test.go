@@ -162,6 +162,7 @@ func initCSIDriverForSanity(t *testing.T) *csiDriver.IBMCSIDriver {
 	mounter := mountManager.NewFakeNodeMounter()
 
 	statsUtil := &MockStatSanity{}
+	deviceUtil := &MockDeviceSanity{}
 
 	// fake node metadata
 	fakeNodeData := nodeMetadata.FakeNodeMetadata{}
@@ -172,7 +173,7 @@ func initCSIDriverForSanity(t *testing.T) *csiDriver.IBMCSIDriver {
 	fakeNodeInfo.NewNodeMetadataReturns(&fakeNodeData, nil)
 
 	// Setup the IBM CSI Driver
-	err := csiSanityDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, &fakeNodeData, &fakeNodeInfo, logger, driver, vendorVersion)
+	err := csiSanityDriver.SetupIBMCSIDriver(provider, mounter, statsUtil, deviceUtil, &fakeNodeData, &fakeNodeInfo, logger, driver, vendorVersion)
 	if err != nil {
 		t.Fatalf("Failed to setup IBM CSI Driver: %v", err)
 	}
@@ -207,6 +208,25 @@ func (su *MockStatSanity) IsDevicePathNotExist(devicePath string) bool {
 	return !strings.Contains(devicePath, TargetPath)
 }
 
+// MockDeviceSanity finds every volume attached
+type MockDeviceSanity struct {
+}
+
+// FindDevice ...
+func (du *MockDeviceSanity) FindDevice(volumeID string) (string, error) {
+	return "/dev/disk/by-id/virtio-" + volumeID, nil
+}
+
+// VerifyDevice ...
+func (du *MockDeviceSanity) VerifyDevice(devicePath string, volumeID string) (bool, error) {
+	return true, nil
+}
+
+// TriggerUdev ...
+func (du *MockDeviceSanity) TriggerUdev(ctx context.Context) error {
+	return nil
+}
+
 // FakeSanityCloudProvider Provider
 type FakeSanityCloudProvider struct {
 	ProviderName   string
@@ -542,6 +562,8 @@ func (c *fakeProviderSession) GetSnapshot(snapshotID string, _ ...string) (*prov
 		fmt.Println("Error")
 		return nil, errors.New("error")
 	}
+	// Snapshot is stable by the time it is looked up again
+	ret.Snapshot.ReadyToUse = true
 	return ret.Snapshot, nil
 }
 